@@ -0,0 +1,57 @@
+package config
+
+import (
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/puppet-evaluator/eval"
+)
+
+// An EntryBuilder configures an Entry
+type EntryBuilder interface {
+	// DataDir sets the data directory of the entry
+	DataDir(dir string)
+
+	// Function sets the data provider function of the entry
+	Function(kind LookupKind, name string)
+
+	// Options sets the options of the entry
+	Options(options eval.OrderedMap)
+}
+
+// A HierarchyEntryBuilder configures a HierarchyEntry
+type HierarchyEntryBuilder interface {
+	EntryBuilder
+
+	// Path adds a path location to the entry
+	Path(path string)
+
+	// Glob adds a glob location to the entry
+	Glob(pattern string)
+
+	// URI adds a uri location to the entry
+	URI(uri string)
+
+	// MappedPaths sets the mapped_paths location of the entry
+	MappedPaths(sourceVar, key, template string)
+
+	// Location adds a location of any kind, e.g. one of a custom kind, to the entry
+	Location(location lookup.Location)
+
+	// Priority sets the priority of the entry
+	Priority(priority int64)
+}
+
+// A Builder creates a Config programmatically, i.e. without reading a configuration file. The
+// created Config is subject to the same rules as one that is read from a file.
+type Builder interface {
+	// Defaults configures the defaults entry
+	Defaults(configure func(eb EntryBuilder)) Builder
+
+	// Hierarchy adds an entry to the hierarchy
+	Hierarchy(name string, configure func(hb HierarchyEntryBuilder)) Builder
+
+	// DefaultHierarchy adds an entry to the default_hierarchy
+	DefaultHierarchy(name string, configure func(hb HierarchyEntryBuilder)) Builder
+
+	// Build returns the created Config
+	Build() Config
+}
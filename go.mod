@@ -1,9 +1,20 @@
 module github.com/lyraproj/hiera
 
 require (
+	github.com/aws/aws-sdk-go v1.16.4
 	github.com/bmatcuk/doublestar v1.1.1
 	github.com/gobwas/glob v0.2.3
 	github.com/lyraproj/issue v0.0.0-20181204205859-7ed1f9741f4a
 	github.com/lyraproj/puppet-evaluator v0.0.0-20181204213239-6c015035abd6
+	github.com/mattn/go-sqlite3 v1.14.22
 	gopkg.in/yaml.v2 v2.2.2
 )
+
+require (
+	github.com/golang/protobuf v1.2.0 // indirect
+	github.com/jmespath/go-jmespath v0.0.0-20180206201540-c2b33e8439af // indirect
+	github.com/lyraproj/data-protobuf v0.0.0-20181204212349-91f456158233 // indirect
+	github.com/lyraproj/puppet-parser v0.0.0-20181204211711-c9870a9ba412 // indirect
+	github.com/lyraproj/semver v0.0.0-20181204205945-997412dbeb0c // indirect
+	gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405 // indirect
+)
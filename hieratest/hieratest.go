@@ -0,0 +1,58 @@
+// Package hieratest provides support for tests that use the lookup engine without reading any files
+// or creating any providers from a configuration.
+package hieratest
+
+import (
+	"fmt"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+// Options returns global options that make every configuration of a lookup resolve to the given
+// providers, in the given order. The options are typically passed to lookup.DoWithParent.
+func Options(providers ...lookup.DataProvider) map[string]eval.Value {
+	return WithProviders(nil, providers, nil)
+}
+
+// WithProviders returns a copy of the given global options, extended with an option that makes every
+// configuration of a lookup resolve to the given hierarchy and default_hierarchy providers.
+func WithProviders(options map[string]eval.Value, providers, defaultProviders []lookup.DataProvider) map[string]eval.Value {
+	no := make(map[string]eval.Value, len(options)+1)
+	for k, v := range options {
+		no[k] = v
+	}
+	no[impl.ConfigOptionKey] = types.WrapRuntime(impl.NewResolvedConfig(providers, defaultProviders))
+	return no
+}
+
+type dataProvider struct {
+	name string
+	data map[string]interface{}
+}
+
+// NewDataProvider returns a provider that finds the root of a key in the given data. The values are
+// converted using eval.Wrap and interpolated the same way as values found in data files.
+func NewDataProvider(name string, data map[string]interface{}) lookup.DataProvider {
+	return &dataProvider{name, data}
+}
+
+func (dp *dataProvider) UncheckedLookup(key lookup.Key, invocation lookup.Invocation, merge lookup.MergeStrategy) (eval.Value, bool) {
+	return invocation.WithDataProvider(dp, func() (eval.Value, bool) {
+		root := key.Root()
+		v, ok := dp.data[root]
+		if !ok {
+			invocation.ReportNotFound(root)
+			return nil, false
+		}
+		value := eval.Wrap(invocation, v)
+		invocation.ReportFound(root, value)
+		return impl.Interpolate(invocation, value, true), true
+	})
+}
+
+func (dp *dataProvider) FullName() string {
+	return fmt.Sprintf(`in-memory provider '%s'`, dp.name)
+}
@@ -0,0 +1,72 @@
+package impl
+
+import (
+	"sync/atomic"
+
+	"github.com/lyraproj/puppet-evaluator/eval"
+)
+
+const HieraCacheStatsKey = `Hiera::CacheStats`
+
+// CacheStats is a snapshot of the number of hits and misses of the caches used by a context that has
+// been initialized with Hiera. The file counts stem from lookup.CachedFileData and the top
+// provider counts stem from the cache of values that the top provider returns.
+type CacheStats struct {
+	FileHits          uint64
+	FileMisses        uint64
+	TopProviderHits   uint64
+	TopProviderMisses uint64
+}
+
+// cacheCounters are updated atomically so that they can be shared between concurrent lookups
+type cacheCounters struct {
+	fileHits          uint64
+	fileMisses        uint64
+	topProviderHits   uint64
+	topProviderMisses uint64
+}
+
+// GetCacheStats returns the cache statistics of the given context. Zero counts are returned when the
+// context hasn't been initialized with Hiera.
+func GetCacheStats(c eval.Context) CacheStats {
+	cc := getCacheCounters(c)
+	if cc == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		FileHits:          atomic.LoadUint64(&cc.fileHits),
+		FileMisses:        atomic.LoadUint64(&cc.fileMisses),
+		TopProviderHits:   atomic.LoadUint64(&cc.topProviderHits),
+		TopProviderMisses: atomic.LoadUint64(&cc.topProviderMisses)}
+}
+
+func getCacheCounters(c eval.Context) *cacheCounters {
+	if v, ok := c.Get(HieraCacheStatsKey); ok {
+		if cc, ok := v.(*cacheCounters); ok {
+			return cc
+		}
+	}
+	return nil
+}
+
+// countFile counts a hit or a miss of the file cache. It is a no-op on a nil receiver.
+func (cc *cacheCounters) countFile(hit bool) {
+	if cc != nil {
+		if hit {
+			atomic.AddUint64(&cc.fileHits, 1)
+		} else {
+			atomic.AddUint64(&cc.fileMisses, 1)
+		}
+	}
+}
+
+// countTopProvider counts a hit or a miss of the top provider cache. It is a no-op on a nil receiver.
+func (cc *cacheCounters) countTopProvider(hit bool) {
+	if cc != nil {
+		if hit {
+			atomic.AddUint64(&cc.topProviderHits, 1)
+		} else {
+			atomic.AddUint64(&cc.topProviderMisses, 1)
+		}
+	}
+}
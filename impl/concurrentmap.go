@@ -57,7 +57,7 @@ func (c *ConcurrentMap) valueMutexWait(key string) (interface{}, bool) {
 // EnsureSet checks if the given key is set and returns it if that is the case. Otherwise
 // it calls the producer and assigns the returned value. The produced value is then returned.
 //
-// The producer does not execute within a mutex.
+// The producer does not execute within a mutex. Nothing is assigned when the producer returns false.
 func (c *ConcurrentMap) EnsureSet(key string, producer func() (interface{}, bool))  (value interface{}, ok bool) {
 	// Take the write lock
 	c.lock.Lock()
@@ -76,6 +76,9 @@ func (c *ConcurrentMap) EnsureSet(key string, producer func() (interface{}, bool
 		c.lock.Lock()
 		if ok {
 			c.values[key] = value
+		} else {
+			// Nothing was produced. Remove the lock so that it isn't mistaken for a pending value
+			delete(c.values, key)
 		}
 		lock.Unlock()
 		c.lock.Unlock()
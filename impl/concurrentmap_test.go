@@ -25,3 +25,19 @@ func TestConcurrentMap_EnsureSet(t *testing.T) {
 	}
 	fmt.Println(c.Get(`hello567`))
 }
+
+func TestConcurrentMap_EnsureSet_notProduced(t *testing.T) {
+	c := NewConcurrentMap(7)
+	c.EnsureSet(`hello`, func() (interface{}, bool) {
+		return nil, false
+	})
+	if _, ok := c.Get(`hello`); ok {
+		t.Fatal(`expected no value for 'hello'`)
+	}
+	v, _ := c.EnsureSet(`hello`, func() (interface{}, bool) {
+		return `world`, true
+	})
+	if v != `world` {
+		t.Errorf(`expected 'world', got %v`, v)
+	}
+}
@@ -8,7 +8,12 @@ import (
 	"github.com/lyraproj/hiera/config"
 	"github.com/lyraproj/hiera/lookup"
 	"github.com/lyraproj/issue/issue"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
 
 	// Ensure that pcore is initialized
 	_ "github.com/lyraproj/puppet-evaluator/pcore"
@@ -55,6 +60,7 @@ func (e *entry) Function() config.Function {
 type hierEntry struct {
 	entry
 	name      string
+	priority  int64
 	locations []lookup.Location
 }
 
@@ -76,42 +82,95 @@ func (e *hierEntry) CreateProvider(ic lookup.Invocation) lookup.DataProvider {
 func (e *hierEntry) Resolve(ic lookup.Invocation, defaults config.Entry) config.HierarchyEntry {
 	// Resolve interpolated strings and locations
 	ce := *e
+	ce.entry = e.entry.resolve(ic, defaults)
 
-	if e.function == nil {
-		e.function = defaults.Function()
-	} else if f, fc := e.function.Resolve(ic); fc {
-		ce.function = f
+	if ce.function == nil {
+		panic(eval.Error(HIERA_MISSING_DATA_PROVIDER_FUNCTION, issue.H{`keys`: config.FUNCTION_KEYS, `name`: e.name}))
 	}
 
-	if e.function == nil {
-		panic(eval.Error(HIERA_MISSING_DATA_PROVIDER_FUNCTION, issue.H{`keys`: config.FUNCTION_KEYS, `name`: e.name}))
+	// Locations must be resolved using the interpolated data directory. A data directory that is a glob
+	// pattern expands to several data roots and the locations are resolved against each one of them.
+	if e.locations != nil {
+		ne := make([]lookup.Location, 0, len(e.locations))
+		for _, dataRoot := range dataRoots(ce.dataDir) {
+			for _, l := range e.locations {
+				ne = append(ne, l.Resolve(ic, dataRoot)...)
+			}
+		}
+		ce.locations = ne
 	}
 
-	if e.dataDir == `` {
-		ce.dataDir = defaults.DataDir()
-	} else {
-		if d, dc := interpolateString(ic, e.dataDir, false); dc {
-			ce.dataDir = d.String()
+	return &ce
+}
+
+// dataRoots returns the given data directory in a slice, or when the directory is a glob pattern, the
+// sorted paths of the directories that match the pattern.
+func dataRoots(dataDir string) []string {
+	if !strings.ContainsAny(dataDir, `*?[{`) {
+		return []string{dataDir}
+	}
+	matches, err := doublestar.Glob(dataDir)
+	if err != nil {
+		panic(eval.Error(HIERA_INVALID_GLOB_PATTERN, issue.H{`pattern`: dataDir, `detail`: err.Error()}))
+	}
+	roots := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+			roots = append(roots, m)
 		}
 	}
+	sort.Strings(roots)
+	return roots
+}
 
-	if e.options == nil {
-		e.options = defaults.Options()
-	} else if e.options.Len() > 0 {
-		if o, oc := doInterpolate(ic, e.options, false); oc {
-			ce.options = o.(*types.HashValue)
+// assertPathsExist panics unless all resolved path locations of the receiver exist. Paths that
+// stem from a glob always exist since they are the result of a match.
+func (e *hierEntry) assertPathsExist() {
+	for _, l := range e.locations {
+		if p, ok := l.(*path); ok && !p.exist {
+			panic(eval.Error(HIERA_MISSING_DATA_FILE, issue.H{`path`: p.resolved, `name`: e.name}))
 		}
 	}
+}
 
-	if e.locations != nil {
-		ne := make([]lookup.Location, 0, len(e.locations))
-		ce.locations = ne
-		for _, l := range e.locations {
-			ne = append(ne, l.Resolve(ic, ce.dataDir)...)
+// resolve returns a copy of the receiver where all interpolated strings have been resolved and
+// unset values have been inherited from the given defaults. The defaults must already be resolved.
+func (e *entry) resolve(ic lookup.Invocation, defaults config.Entry) entry {
+	ce := *e
+
+	if e.function == nil {
+		if defaults != nil {
+			ce.function = defaults.Function()
 		}
+	} else if f, fc := e.function.Resolve(ic); fc {
+		ce.function = f
 	}
 
-	return &ce
+	if e.dataDir == `` {
+		if defaults != nil {
+			ce.dataDir = defaults.DataDir()
+		}
+	} else if d, dc := interpolateString(ic, e.dataDir, false); dc {
+		ce.dataDir = d.String()
+	}
+
+	if e.options == nil {
+		if defaults != nil {
+			ce.options = defaults.Options()
+		}
+	} else {
+		if e.options.Len() > 0 {
+			if o, oc := doInterpolate(ic, e.options, false); oc {
+				ce.options = o.(*types.HashValue)
+			}
+		}
+		// Options of the entry are merged over the default options unless the old behavior,
+		// where the options of the entry replace the defaults entirely, has been requested.
+		if defaults != nil && defaults.Options() != nil && !booleanOption(ic, ReplaceOptionsOptionKey) {
+			ce.options = defaults.Options().Merge(ce.options)
+		}
+	}
+	return ce
 }
 
 var hieraTypeSet eval.TypeSet
@@ -129,7 +188,7 @@ func init() {
 				Optional[data_dig] => String[1],
 				Optional[data_hash] => String[1],
 				Optional[lookup_key] => String[1],
-				Optional[data_dir] => String[1],
+				Optional[datadir] => String[1],
 			}],
 			Entry => Struct[{
 				name => String[1],
@@ -137,7 +196,7 @@ func init() {
 				Optional[data_dig] => String[1],
 				Optional[data_hash] => String[1],
 				Optional[lookup_key] => String[1],
-				Optional[data_dir] => String[1],
+				Optional[datadir] => String[1],
 				Optional[path] => String[1],
 				Optional[paths] => Array[String[1], 1],
 				Optional[glob] => String[1],
@@ -145,6 +204,7 @@ func init() {
 				Optional[uri] => String[1],
 				Optional[uris] => Array[String[1], 1],
 				Optional[mapped_paths] => Array[String[1], 3, 3],
+				Optional[priority] => Integer,
 			}],
 			Config => Struct[{
 				version => Integer[5, 5],
@@ -176,8 +236,33 @@ type hieraCfg struct {
 	defaultHierarchy []config.HierarchyEntry
 }
 
+// NewConfig reads the configuration from the given path. DEFAULT_CONFIG is returned when no file exists
+// at the path. When the global FallbackConfigOptionKey option is set, a configuration that cannot be
+// loaded is reported as a warning and the configuration is read from the fallback path instead.
 func NewConfig(ic lookup.Invocation, configPath string) config.Config {
+	fv, ok := globalOption(ic, FallbackConfigOptionKey)
+	if !ok {
+		return loadConfig(ic, configPath)
+	}
+	return loadConfigWithFallback(ic, configPath, fv.String())
+}
 
+func loadConfigWithFallback(ic lookup.Invocation, configPath, fallbackPath string) (cfg config.Config) {
+	defer func() {
+		if r := recover(); r != nil {
+			ri, ok := r.(issue.Reported)
+			if !ok {
+				panic(r)
+			}
+			ic.Logger().Logf(eval.WARNING, `Unable to load the configuration at '%s', using '%s' instead: %s`,
+				configPath, fallbackPath, strings.TrimSpace(ri.Error()))
+			cfg = loadConfig(ic, fallbackPath)
+		}
+	}()
+	return loadConfig(ic, configPath)
+}
+
+func loadConfig(ic lookup.Invocation, configPath string) config.Config {
 	// TODO: Cache parsed file content
 	if b, ok := types.BinaryFromFile2(ic, configPath); ok {
 		v, ok := eval.Load(ic, eval.NewTypedName(eval.NsType, `Hiera::Config`))
@@ -186,9 +271,10 @@ func NewConfig(ic lookup.Invocation, configPath string) config.Config {
 		}
 		cfgType := v.(eval.Type)
 		yv := UnmarshalYaml(ic, b.Bytes())
-		return createConfig(ic, configPath, eval.AssertInstance(func() string {
+		eval.AssertInstance(func() string {
 				return fmt.Sprintf(`The Lookup Configuration at '%s'`, configPath)
-			}, cfgType, yv).(*types.HashValue))
+			}, cfgType, withoutCustomLocations(yv))
+		return createConfig(ic, configPath, yv.(*types.HashValue))
 	}
 	return DEFAULT_CONFIG
 }
@@ -223,14 +309,49 @@ func (hc *hieraCfg) Defaults() config.Entry {
 }
 
 func (hc *hieraCfg) CreateProviders(ic lookup.Invocation, hierarchy []config.HierarchyEntry) []lookup.DataProvider {
+	hc.assertHierarchyLevels(ic, hierarchy)
+	return hc.createProviders(ic, hierarchy, booleanOption(ic, RequireAllPathsOptionKey))
+}
+
+func (hc *hieraCfg) createProviders(ic lookup.Invocation, hierarchy []config.HierarchyEntry, requirePaths bool) []lookup.DataProvider {
 	providers := make([]lookup.DataProvider, len(hierarchy))
-	defaults := hc.defaults.(*hierEntry).Resolve(ic, nil)
-	for i, he := range hierarchy {
-		providers[i] = he.(*hierEntry).Resolve(ic, defaults).CreateProvider(ic)
+	defaults := hc.defaults.(*entry).resolve(ic, DEFAULT_CONFIG.Defaults())
+	// The providers are created in priority order. Lookups consult them, and the explanation lists them,
+	// in that effective order rather than in the order that the entries are declared.
+	for i, he := range prioritized(hierarchy) {
+		re := he.(*hierEntry).Resolve(ic, &defaults).(*hierEntry)
+		if requirePaths {
+			re.assertPathsExist()
+		}
+		ic.Explain(func() string {
+			return fmt.Sprintf(`Hierarchy entry '%s' with priority %d`, re.name, re.priority)
+		})
+		providers[i] = re.CreateProvider(ic)
 	}
 	return providers
 }
 
+// assertHierarchyLevels panics if the given hierarchy has more levels than the maximum number of
+// hierarchy levels
+func (hc *hieraCfg) assertHierarchyLevels(ic lookup.Invocation, hierarchy []config.HierarchyEntry) {
+	if v, ok := globalOption(ic, MaxHierarchyLevelsOptionKey); ok {
+		if iv, ok := v.(*types.IntegerValue); ok && int64(len(hierarchy)) > iv.Int() {
+			panic(eval.Error(HIERA_TOO_MANY_HIERARCHY_LEVELS, issue.H{`path`: hc.path, `count`: len(hierarchy), `max`: iv.Int()}))
+		}
+	}
+}
+
+// prioritized returns the given hierarchy ordered by entry priority, highest first. Entries that
+// have equal priority retain their declared order. The default priority is zero.
+func prioritized(hierarchy []config.HierarchyEntry) []config.HierarchyEntry {
+	sorted := make([]config.HierarchyEntry, len(hierarchy))
+	copy(sorted, hierarchy)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].(*hierEntry).priority > sorted[j].(*hierEntry).priority
+	})
+	return sorted
+}
+
 func createConfig(ic lookup.Invocation, path string, hash *types.HashValue) config.Config {
 	cfg := &hieraCfg{root: filepath.Dir(path), path: path}
 
@@ -278,6 +399,8 @@ func (entry* entry) initialize(ic lookup.Invocation, name string, entryHash *typ
 					panic(eval.Error(HIERA_OPTION_RESERVED_BY_PUPPET, issue.H{`key`: optKey.String(), `name`: name}))
 				}
 			})
+		} else if ks == `datadir` {
+			entry.dataDir = v.String()
 		} else if utils.ContainsString(config.FUNCTION_KEYS, ks) {
 			if entry.function != nil {
 				panic(eval.Error(HIERA_MULTIPLE_DATA_PROVIDER_FUNCTIONS, issue.H{`keys`: config.FUNCTION_KEYS, `name`: name}))
@@ -298,9 +421,11 @@ func createHierarchyEntry(ic lookup.Invocation, name string, entryHash *types.Ha
 	entry.initialize(ic, name, entryHash)
 	entryHash.EachPair(func(k, v eval.Value) {
 		ks := k.String()
-		if utils.ContainsString(config.LOCATION_KEYS, ks) {
+		if ks == `priority` {
+			entry.priority = v.(*types.IntegerValue).Int()
+		} else if utils.ContainsString(config.LOCATION_KEYS, ks) {
 			if entry.locations != nil {
-				panic(eval.Error(HIERA_MULTIPLE_LOCATION_SPECS, issue.H{`keys`: config.LOCATION_KEYS, `name`: name}))
+				panic(eval.Error(HIERA_MULTIPLE_LOCATION_SPECS, issue.H{`keys`: locationKeys(), `name`: name}))
 			}
 			switch ks {
 			case `path`:
@@ -325,6 +450,11 @@ func createHierarchyEntry(ic lookup.Invocation, name string, entryHash *types.Ha
 				a := v.(*types.ArrayValue)
 				entry.locations = []lookup.Location{&mappedPaths{a.At(0).String(), a.At(1).String(), a.At(2).String()}}
 			}
+		} else if factory, ok := locationFactory(ks); ok {
+			if entry.locations != nil {
+				panic(eval.Error(HIERA_MULTIPLE_LOCATION_SPECS, issue.H{`keys`: locationKeys(), `name`: name}))
+			}
+			entry.locations = factory(v)
 		}
 	})
 	return entry
@@ -337,6 +467,17 @@ type resolvedConfig struct {
 	defaultProviders []lookup.DataProvider
 }
 
+// NewResolvedConfig returns a resolved configuration that uses the given providers verbatim. No hierarchy
+// entries are resolved and no providers are created, which makes it possible to use in-memory providers
+// in tests. The returned configuration is never re-resolved.
+func NewResolvedConfig(providers, defaultProviders []lookup.DataProvider) config.ResolvedConfig {
+	return &resolvedConfig{
+		config:           &hieraCfg{defaults: DEFAULT_CONFIG.Defaults()},
+		variablesUsed:    map[string]eval.Value{},
+		providers:        providers,
+		defaultProviders: defaultProviders}
+}
+
 func (r *resolvedConfig) ReResolve(ic lookup.Invocation) config.ResolvedConfig {
 	if r.variablesUsed == nil {
 		r.Resolve(ic)
@@ -377,7 +518,7 @@ func (r *resolvedConfig) Resolve(ic lookup.Invocation) {
 	ts := NewTrackingScope(ic.Scope())
 	ic.DoWithScope(ts, func() {
 		r.providers = r.config.CreateProviders(ic, r.config.Hierarchy())
-		r.defaultProviders = r.config.CreateProviders(ic, r.config.DefaultHierarchy())
+		r.defaultProviders = r.config.createProviders(ic, r.config.DefaultHierarchy(), false)
 	})
 	r.variablesUsed = ts.GetRead()
 }
\ No newline at end of file
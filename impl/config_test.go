@@ -0,0 +1,266 @@
+package impl
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	evalimpl "github.com/lyraproj/puppet-evaluator/impl"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+func withFacts(t *testing.T, facts map[string]interface{}, actor func(ic *invocation)) {
+	t.Helper()
+	if err := runWithFacts(facts, nil, actor); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runWithFacts(facts map[string]interface{}, options map[string]eval.Value, actor func(ic *invocation)) error {
+	return eval.Puppet.TryWithParent(context.Background(), func(c eval.Context) error {
+		InitContext(c, nil, options)
+		c.DoWithScope(evalimpl.NewScope2(types.WrapStringToInterfaceMap(c, map[string]interface{}{`facts`: facts}), false), func() {
+			actor(NewInvocation(c).(*invocation))
+		})
+		return nil
+	})
+}
+
+func TestHierEntry_Resolve_interpolatedDataDir(t *testing.T) {
+	withFacts(t, map[string]interface{}{`tenant`: `acme`}, func(ic *invocation) {
+		hc := NewConfig(ic, `testdata/tenants/hiera.yaml`).(*hieraCfg)
+		defaults := hc.defaults.(*entry).resolve(ic, DEFAULT_CONFIG.Defaults())
+		if defaults.dataDir != `testdata/tenants/acme/data` {
+			t.Fatalf(`unexpected defaults datadir '%s'`, defaults.dataDir)
+		}
+		for _, he := range hc.Hierarchy() {
+			re := he.(*hierEntry).Resolve(ic, &defaults).(*hierEntry)
+			if len(re.locations) != 1 {
+				t.Fatalf(`expected one resolved location for '%s', got %d`, re.name, len(re.locations))
+			}
+			p := re.locations[0].(*path)
+			if p.resolved != `testdata/tenants/acme/data/common.yaml` || !p.Exist() {
+				t.Errorf(`unexpected location for '%s': %s`, re.name, p)
+			}
+		}
+	})
+}
+
+// explainRecorder is an invocation that records the messages that it is asked to explain
+type explainRecorder struct {
+	lookup.Invocation
+	messages []string
+}
+
+func (e *explainRecorder) Explain(messageProducer func() string) {
+	e.messages = append(e.messages, messageProducer())
+}
+
+func TestCreateProviders_priority(t *testing.T) {
+	withFacts(t, map[string]interface{}{}, func(ic *invocation) {
+		hc := NewConfig(ic, `testdata/priority/hiera.yaml`).(*hieraCfg)
+		er := &explainRecorder{Invocation: ic}
+		if len(hc.CreateProviders(er, hc.Hierarchy())) != 5 {
+			t.Error(`expected five providers`)
+		}
+		expected := []string{
+			`Hierarchy entry 'High' with priority 10`,
+			`Hierarchy entry 'First' with priority 0`,
+			`Hierarchy entry 'Second' with priority 0`,
+			`Hierarchy entry 'Third' with priority 0`,
+			`Hierarchy entry 'Low' with priority -1`}
+		if s := strings.Join(er.messages, `, `); s != strings.Join(expected, `, `) {
+			t.Errorf(`unexpected order: %s`, s)
+		}
+	})
+}
+
+func TestResolvedConfig_requireAllPaths(t *testing.T) {
+	resolve := func(ic *invocation) {
+		NewConfig(ic, `testdata/required/hiera.yaml`).Resolve(ic)
+	}
+	if err := runWithFacts(nil, nil, resolve); err != nil {
+		t.Errorf(`expected missing paths to be accepted by default, got %s`, err)
+	}
+
+	err := runWithFacts(nil, map[string]eval.Value{RequireAllPathsOptionKey: types.WrapBoolean(true)}, resolve)
+	if err == nil {
+		t.Fatal(`expected an error for a missing path`)
+	}
+	expected := `Data file 'testdata/required/data/missing.yaml' used in hierarchy 'Missing' does not exist`
+	if strings.TrimSpace(err.Error()) != expected {
+		t.Errorf(`expected '%s', got '%s'`, expected, err)
+	}
+}
+
+func TestResolvedConfig_maxHierarchyLevels(t *testing.T) {
+	resolve := func(ic *invocation) {
+		NewConfig(ic, `testdata/priority/hiera.yaml`).Resolve(ic)
+	}
+	if err := runWithFacts(nil, map[string]eval.Value{MaxHierarchyLevelsOptionKey: types.WrapInteger(5)}, resolve); err != nil {
+		t.Errorf(`expected a hierarchy within the limit to be accepted, got %s`, err)
+	}
+
+	err := runWithFacts(nil, map[string]eval.Value{MaxHierarchyLevelsOptionKey: types.WrapInteger(3)}, resolve)
+	if err == nil {
+		t.Fatal(`expected an error for a hierarchy that exceeds the limit`)
+	}
+	expected := `The hierarchy of 'testdata/priority/hiera.yaml' has 5 levels which exceeds the maximum of 3 levels`
+	if strings.TrimSpace(err.Error()) != expected {
+		t.Errorf(`expected '%s', got '%s'`, expected, err)
+	}
+}
+
+func TestHierEntry_Resolve_options(t *testing.T) {
+	resolvedOptions := func(options map[string]eval.Value) []string {
+		var result []string
+		err := runWithFacts(map[string]interface{}{`c`: `c`}, options, func(ic *invocation) {
+			hc := NewConfig(ic, `testdata/options/hiera.yaml`).(*hieraCfg)
+			defaults := hc.defaults.(*entry).resolve(ic, DEFAULT_CONFIG.Defaults())
+			for _, he := range hc.Hierarchy() {
+				result = append(result, he.(*hierEntry).Resolve(ic, &defaults).Options().String())
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	merged := resolvedOptions(nil)
+	if merged[0] != `{'a' => 'default a', 'b' => 'default b'}` {
+		t.Errorf(`unexpected inherited options %s`, merged[0])
+	}
+	if merged[1] != `{'a' => 'default a', 'b' => 'entry b', 'c' => 'entry c'}` {
+		t.Errorf(`unexpected merged options %s`, merged[1])
+	}
+
+	replaced := resolvedOptions(map[string]eval.Value{ReplaceOptionsOptionKey: types.WrapBoolean(true)})
+	if replaced[1] != `{'b' => 'entry b', 'c' => 'entry c'}` {
+		t.Errorf(`unexpected replaced options %s`, replaced[1])
+	}
+}
+
+func TestInvocation_Config_fromOption(t *testing.T) {
+	cfg := NewConfigBuilder(`testdata/tenants`).
+		Hierarchy(`Built`, func(hb config.HierarchyEntryBuilder) { hb.Path(`common.yaml`) }).
+		Build()
+	err := runWithFacts(nil, map[string]eval.Value{ConfigOptionKey: types.WrapRuntime(cfg)}, func(ic *invocation) {
+		rc := ic.Config(`testdata/tenants/hiera.yaml`)
+		if rc.Config() != cfg {
+			t.Error(`expected the configuration given in the options`)
+		}
+		if rc != ic.Config(`testdata/tenants/hiera.yaml`) {
+			t.Error(`expected the resolved configuration to be cached`)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type fakeProvider string
+
+func (p fakeProvider) UncheckedLookup(key lookup.Key, invocation lookup.Invocation, merge lookup.MergeStrategy) (eval.Value, bool) {
+	return types.WrapString(string(p) + `:` + key.Root()), true
+}
+
+func (p fakeProvider) FullName() string {
+	return string(p)
+}
+
+func TestInvocation_Config_resolvedFromOption(t *testing.T) {
+	providers := []lookup.DataProvider{fakeProvider(`first`), fakeProvider(`second`)}
+	rc := NewResolvedConfig(providers, nil)
+	err := runWithFacts(map[string]interface{}{`tenant`: `acme`}, map[string]eval.Value{ConfigOptionKey: types.WrapRuntime(rc)}, func(ic *invocation) {
+		if ic.Config(`testdata/tenants/hiera.yaml`) != rc {
+			t.Fatal(`expected the resolved configuration given in the options`)
+		}
+		if rc.ReResolve(ic) != rc {
+			t.Error(`expected the resolved configuration to never be re-resolved`)
+		}
+		hp := rc.Hierarchy()
+		if len(hp) != 2 || len(rc.DefaultHierarchy()) != 0 {
+			t.Fatalf(`unexpected providers %v`, hp)
+		}
+		if v, ok := CheckedLookup(hp[1], NewKey(`a.b`), ic, nil); !ok || v.String() != `second:a` {
+			t.Errorf(`unexpected value %v`, v)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHierEntry_Resolve_globbedDataDir(t *testing.T) {
+	withFacts(t, map[string]interface{}{}, func(ic *invocation) {
+		hc := NewConfig(ic, `testdata/multiroot/hiera.yaml`).(*hieraCfg)
+		defaults := hc.defaults.(*entry).resolve(ic, DEFAULT_CONFIG.Defaults())
+		expected := map[string][]string{
+			`Environments`: {`testdata/multiroot/envs/prod/data/common.yaml`, `testdata/multiroot/envs/staging/data/common.yaml`},
+			`Single root`:  {`testdata/multiroot/envs/prod/data/common.yaml`}}
+		for _, he := range hc.Hierarchy() {
+			re := he.(*hierEntry).Resolve(ic, &defaults).(*hierEntry)
+			paths := expected[re.name]
+			if len(re.locations) != len(paths) {
+				t.Fatalf(`expected %d resolved locations for '%s', got %v`, len(paths), re.name, re.locations)
+			}
+			for i, l := range re.locations {
+				if p := l.(*path); p.resolved != filepath.FromSlash(paths[i]) || !p.Exist() {
+					t.Errorf(`unexpected location for '%s': %s`, re.name, p)
+				}
+			}
+		}
+	})
+}
+
+func TestNewConfig_fallback(t *testing.T) {
+	load := func(ic *invocation) {
+		hc := NewConfig(ic, `testdata/fallback/broken.yaml`)
+		if hc.Path() != `testdata/fallback/hiera.yaml` || hc.Hierarchy()[0].Name() != `Known good` {
+			t.Errorf(`expected the fallback configuration, got '%s'`, hc.Path())
+		}
+	}
+	if err := runWithFacts(nil, nil, load); err == nil {
+		t.Error(`expected an error for a broken configuration without a fallback`)
+	}
+
+	logger := eval.NewArrayLogger()
+	err := eval.Puppet.TryWithParent(context.Background(), func(c eval.Context) error {
+		lc := evalimpl.WithParent(c, evalimpl.NewEvaluator, c.Loader(), logger, c.ImplementationRegistry())
+		InitContext(lc, nil, map[string]eval.Value{FallbackConfigOptionKey: types.WrapString(`testdata/fallback/hiera.yaml`)})
+		load(NewInvocation(lc).(*invocation))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := logger.Entries(eval.WARNING); len(warnings) != 1 || !strings.Contains(warnings[0].Message(), `testdata/fallback/broken.yaml`) {
+		t.Errorf(`expected a warning naming the broken configuration, got %v`, warnings)
+	}
+}
+
+func TestResolvedConfig_defaultHierarchyFacts(t *testing.T) {
+	withFacts(t, map[string]interface{}{`tenant`: `acme`}, func(ic *invocation) {
+		hc := NewConfig(ic, `testdata/defaulthierarchy/hiera.yaml`).(*hieraCfg)
+		defaults := hc.defaults.(*entry).resolve(ic, DEFAULT_CONFIG.Defaults())
+		if len(hc.DefaultHierarchy()) != 1 {
+			t.Fatalf(`expected one default hierarchy entry, got %d`, len(hc.DefaultHierarchy()))
+		}
+		re := hc.DefaultHierarchy()[0].(*hierEntry).Resolve(ic, &defaults).(*hierEntry)
+		if len(re.locations) != 1 {
+			t.Fatalf(`expected one resolved location, got %v`, re.locations)
+		}
+		if p := re.locations[0].(*path); p.resolved != filepath.FromSlash(`testdata/defaulthierarchy/data/acme.yaml`) || !p.Exist() {
+			t.Errorf(`unexpected default hierarchy location: %s`, p)
+		}
+
+		if n := len(hc.Resolve(ic).DefaultHierarchy()); n != 1 {
+			t.Errorf(`expected one default hierarchy provider, got %d`, n)
+		}
+	})
+}
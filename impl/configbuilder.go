@@ -0,0 +1,122 @@
+package impl
+
+import (
+	"github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/utils"
+)
+
+type entryBuilder struct {
+	name  string
+	entry *entry
+}
+
+func (b *entryBuilder) DataDir(dir string) {
+	b.entry.dataDir = dir
+}
+
+func (b *entryBuilder) Function(kind config.LookupKind, name string) {
+	if b.entry.function != nil {
+		panic(eval.Error(HIERA_MULTIPLE_DATA_PROVIDER_FUNCTIONS, issue.H{`keys`: config.FUNCTION_KEYS, `name`: b.name}))
+	}
+	b.entry.function = &function{kind, name}
+}
+
+func (b *entryBuilder) Options(options eval.OrderedMap) {
+	options.EachKey(func(optKey eval.Value) {
+		if utils.ContainsString(config.RESERVED_OPTION_KEYS, optKey.String()) {
+			panic(eval.Error(HIERA_OPTION_RESERVED_BY_PUPPET, issue.H{`key`: optKey.String(), `name`: b.name}))
+		}
+	})
+	b.entry.options = options
+}
+
+type hierEntryBuilder struct {
+	entryBuilder
+	hierEntry *hierEntry
+}
+
+func (b *hierEntryBuilder) addLocation(l lookup.Location) {
+	if len(b.hierEntry.locations) > 0 && b.hierEntry.locations[0].Kind() != l.Kind() {
+		panic(eval.Error(HIERA_MULTIPLE_LOCATION_SPECS, issue.H{`keys`: locationKeys(), `name`: b.name}))
+	}
+	b.hierEntry.locations = append(b.hierEntry.locations, l)
+}
+
+func (b *hierEntryBuilder) Path(p string) {
+	b.addLocation(&path{original: p})
+}
+
+func (b *hierEntryBuilder) Glob(pattern string) {
+	b.addLocation(&glob{pattern})
+}
+
+func (b *hierEntryBuilder) URI(u string) {
+	b.addLocation(&uri{original: u})
+}
+
+func (b *hierEntryBuilder) MappedPaths(sourceVar, key, template string) {
+	if len(b.hierEntry.locations) > 0 {
+		panic(eval.Error(HIERA_MULTIPLE_LOCATION_SPECS, issue.H{`keys`: locationKeys(), `name`: b.name}))
+	}
+	b.addLocation(&mappedPaths{sourceVar, key, template})
+}
+
+func (b *hierEntryBuilder) Location(l lookup.Location) {
+	b.addLocation(l)
+}
+
+func (b *hierEntryBuilder) Priority(priority int64) {
+	b.hierEntry.priority = priority
+}
+
+type configBuilder struct {
+	cfg         *hieraCfg
+	uniqueNames map[string]bool
+}
+
+// NewConfigBuilder returns a builder that creates a Config programmatically. The given root is
+// the directory that the Config would have been read from.
+func NewConfigBuilder(root string) config.Builder {
+	return &configBuilder{cfg: &hieraCfg{root: root}, uniqueNames: make(map[string]bool)}
+}
+
+func (b *configBuilder) Defaults(configure func(eb config.EntryBuilder)) config.Builder {
+	defaults := &entry{}
+	configure(&entryBuilder{`defaults`, defaults})
+	b.cfg.defaults = defaults
+	return b
+}
+
+func (b *configBuilder) Hierarchy(name string, configure func(hb config.HierarchyEntryBuilder)) config.Builder {
+	b.cfg.hierarchy = append(b.cfg.hierarchy, b.buildEntry(name, configure))
+	return b
+}
+
+func (b *configBuilder) DefaultHierarchy(name string, configure func(hb config.HierarchyEntryBuilder)) config.Builder {
+	b.cfg.defaultHierarchy = append(b.cfg.defaultHierarchy, b.buildEntry(name, configure))
+	return b
+}
+
+func (b *configBuilder) buildEntry(name string, configure func(hb config.HierarchyEntryBuilder)) config.HierarchyEntry {
+	if b.uniqueNames[name] {
+		panic(eval.Error(HIERA_HIERARCHY_NAME_MULTIPLY_DEFINED, issue.H{`name`: name}))
+	}
+	b.uniqueNames[name] = true
+	he := &hierEntry{name: name}
+	configure(&hierEntryBuilder{entryBuilder{name, &he.entry}, he})
+	return he
+}
+
+func (b *configBuilder) Build() config.Config {
+	cfg := *b.cfg
+	if cfg.defaults == nil {
+		cfg.defaults = DEFAULT_CONFIG.Defaults()
+	}
+	if cfg.hierarchy == nil {
+		cfg.hierarchy = DEFAULT_CONFIG.Hierarchy()
+	}
+	return &cfg
+}
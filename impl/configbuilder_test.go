@@ -0,0 +1,50 @@
+package impl_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/hiera/provider"
+	"github.com/lyraproj/puppet-evaluator/eval"
+)
+
+func ExampleNewConfigBuilder() {
+	cfg := impl.NewConfigBuilder(`/etc/hiera`).
+		Defaults(func(eb config.EntryBuilder) {
+			eb.DataDir(`data`)
+			eb.Function(config.DATA_HASH, `yaml_data`)
+		}).
+		Hierarchy(`Per node`, func(hb config.HierarchyEntryBuilder) {
+			hb.Path(`nodes/%{trusted.certname}.yaml`)
+		}).
+		Hierarchy(`Common`, func(hb config.HierarchyEntryBuilder) {
+			hb.Path(`common.yaml`)
+		}).
+		Build()
+
+	for _, he := range cfg.Hierarchy() {
+		fmt.Println(he.Name())
+	}
+
+	lookup.DoWithParent(context.Background(), provider.Yaml, nil, func(c eval.Context) {
+		fmt.Println(len(cfg.Resolve(impl.NewInvocation(c)).Hierarchy()))
+	})
+	// Output:
+	// Per node
+	// Common
+	// 2
+}
+
+func ExampleNewConfigBuilder_multipleLocationSpecs() {
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, nil, func(c eval.Context) error {
+		impl.NewConfigBuilder(`/etc/hiera`).Hierarchy(`Mixed`, func(hb config.HierarchyEntryBuilder) {
+			hb.Path(`common.yaml`)
+			hb.Glob(`*.yaml`)
+		})
+		return nil
+	}))
+	// Output: Only one of path, paths, glob, globs, uri, uris, mapped_paths can be defined in hierarchy 'Mixed'
+}
@@ -2,7 +2,9 @@ package impl
 
 import (
 	"context"
+	"fmt"
 	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
 	"github.com/lyraproj/hiera/lookup"
 	"github.com/lyraproj/issue/issue"
 )
@@ -24,6 +26,34 @@ func init() {
 		})
 	}
 
+	lookup.Find = func(ic lookup.Invocation, name string, options map[string]eval.Value) (eval.Value, bool) {
+		if options == nil {
+			options = NoOptions
+		}
+		return lookupName(ic.(*invocation), name, eval.EMPTY_MAP, options)
+	}
+
+	lookup.LookupBatch = func(ic lookup.Invocation, names []string, options map[string]eval.Value) *lookup.BatchResult {
+		if options == nil {
+			options = NoOptions
+		}
+		result := &lookup.BatchResult{}
+		entries := make([]*types.HashEntry, 0, len(names))
+		for _, name := range names {
+			v, ok, err := tryLookupName(ic.(*invocation), name, options)
+			switch {
+			case err != nil:
+				result.Errors = append(result.Errors, lookup.KeyError{Key: name, Error: err})
+			case ok:
+				entries = append(entries, types.WrapHashEntry2(name, v))
+			default:
+				result.NotFound = append(result.NotFound, name)
+			}
+		}
+		result.Values = types.WrapHash(entries)
+		return result
+	}
+
 	lookup.Lookup2 = func(
 			ic lookup.Invocation,
 			names []string,
@@ -44,22 +74,22 @@ func init() {
 			options = NoOptions
 		}
 
-		for _, name := range names {
-			if ov, ok := override.Get4(name); ok {
-				return ov
-			}
-			key := NewKey(name)
-			if v, ok := ic.Check(key, func() (eval.Value, bool) {
-				return ic.(*invocation).lookupViaCache(key, options)
-			}); ok {
+		// The rewritten names apply to the lookups, the default values hash, and the type of the default
+		rewritten := make([]string, len(names))
+		for i, name := range names {
+			rewritten[i] = ic.(*invocation).rewriteKey(name)
+		}
+
+		for _, name := range rewritten {
+			if v, ok := lookupRewrittenName(ic.(*invocation), name, override, options); ok {
 				return v
 			}
 		}
 
 		if defaultValuesHash.Len() > 0 {
-			for _, name := range names {
+			for _, name := range rewritten {
 				if dv, ok := defaultValuesHash.Get4(name); ok {
-					return dv
+					return ic.(*invocation).bindType(name, dv)
 				}
 			}
 		}
@@ -71,6 +101,45 @@ func init() {
 			}
 			panic(eval.Error(HIERA_NOT_ANY_NAME_FOUND, issue.H{`name_list`: names}))
 		}
-		return defaultValue
+		if defaultValue == eval.UNDEF || len(names) == 0 {
+			return defaultValue
+		}
+		// The default must match the type that the key is bound to
+		return ic.(*invocation).bindType(rewritten[0], defaultValue)
+	}
+}
+
+// tryLookupName is like lookupName but it recovers a panic and returns it as an error
+func tryLookupName(ic *invocation, name string, options map[string]eval.Value) (v eval.Value, ok bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if re, isErr := r.(error); isErr {
+				err = re
+			} else {
+				err = fmt.Errorf(`%v`, r)
+			}
+		}
+	}()
+	v, ok = lookupName(ic, name, eval.EMPTY_MAP, options)
+	return
+}
+
+// lookupName returns the value for the given name together with a boolean to indicate if the value was
+// found. The value is taken from the override hash when it contains the name.
+func lookupName(ic *invocation, name string, override eval.OrderedMap, options map[string]eval.Value) (eval.Value, bool) {
+	return lookupRewrittenName(ic, ic.rewriteKey(name), override, options)
+}
+
+// lookupRewrittenName is like lookupName but the given name has already been rewritten
+func lookupRewrittenName(ic *invocation, name string, override eval.OrderedMap, options map[string]eval.Value) (eval.Value, bool) {
+	if ov, ok := override.Get4(name); ok {
+		return ov, true
+	}
+	key := NewKey(name)
+	if v, ok := ic.Check(key, func() (eval.Value, bool) {
+		return ic.lookupViaCache(key, options)
+	}); ok {
+		return ic.bindType(name, v), true
 	}
+	return nil, false
 }
@@ -3,10 +3,14 @@ package impl_test
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 	"github.com/lyraproj/puppet-evaluator/eval"
 	evalimpl "github.com/lyraproj/puppet-evaluator/impl"
 	"github.com/lyraproj/puppet-evaluator/types"
 	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/hieratest"
 	"github.com/lyraproj/hiera/lookup"
 	"github.com/lyraproj/hiera/provider"
 	"github.com/lyraproj/issue/issue"
@@ -43,6 +47,18 @@ func ExampleLookup_dottedMix() {
 	// Output: value of first
 }
 
+func ExampleLookup_quotedKey() {
+	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `"my.dotted.key"`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `'my.dotted.hash'.'sub.key'`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `my.dotted.key`, types.WrapString(`not found`), nil))
+	})
+	// Output:
+	// dotted value
+	// quoted sub value
+	// not found
+}
+
 func ExampleLookup_interpolate() {
 	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
 		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `second`, nil, nil))
@@ -50,6 +66,103 @@ func ExampleLookup_interpolate() {
 	// Output: includes 'value of first'
 }
 
+func ExampleLookup_raw() {
+	rawOptions := map[string]eval.Value{
+		`path`:            options[`path`],
+		impl.RawOptionKey: types.WrapBoolean(true)}
+
+	lookup.DoWithParent(context.Background(), provider.Yaml, rawOptions, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `second`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `hash.array.1`, nil, nil))
+	})
+	// Output:
+	// includes '%{lookup('first')}'
+	// %{hiera('first')}
+}
+
+func ExampleLookup_keyRewrite() {
+	rewriteOptions := map[string]eval.Value{
+		`path`: options[`path`],
+		impl.KeyRewriteOptionKey: types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{
+			`old::first`: `first`,
+			`first`:      `second`})}
+	lookup.DoWithParent(context.Background(), provider.Yaml, rewriteOptions, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `old::first`, nil, nil))
+
+		// The lookup of 'first' from the interpolation in 'second' is not rewritten
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `first`, nil, nil))
+	})
+	// Output:
+	// debug: lookup key 'old::first' rewritten to 'first'
+	// value of first
+	// debug: lookup key 'first' rewritten to 'second'
+	// includes 'value of first'
+}
+
+func ExampleLookup_keyRewriteDefaults() {
+	rewriteOptions := map[string]eval.Value{
+		`path`: options[`path`],
+		impl.KeyRewriteOptionKey: types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{
+			`old::port`: `port`}),
+		impl.KeyTypesOptionKey: types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{
+			`port`: `Integer[1,65535]`})}
+	defaults := types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{`port`: 8080})
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, rewriteOptions, func(c eval.Context) error {
+		// The default values hash and the key type apply to the rewritten key
+		fmt.Println(lookup.Lookup2(impl.NewInvocation(c), []string{`old::port`}, types.DefaultAnyType(), nil, nil, defaults, nil, nil))
+		lookup.Lookup(impl.NewInvocation(c), `old::port`, types.WrapString(`http`), nil)
+		return nil
+	}))
+	// Output:
+	// debug: lookup key 'old::port' rewritten to 'port'
+	// 8080
+	// debug: lookup key 'old::port' rewritten to 'port'
+	// Type mismatch:  value for key 'port' expects an Integer value, got String
+}
+
+func ExampleLookup_keyTypes() {
+	typedOptions := map[string]eval.Value{
+		`path`: options[`path`],
+		impl.KeyTypesOptionKey: types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{
+			`hash`:   `Test::Hash`,
+			`second`: `Integer`})}
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, typedOptions, func(c eval.Context) error {
+		c.AddTypes(eval.NewObjectType(`Test::Hash`, `{
+      attributes => {
+        int => Integer,
+        string => String,
+        array => Array[String]
+      }
+    }`))
+		v := lookup.Lookup(impl.NewInvocation(c), `hash`, nil, nil)
+		s, _ := v.(eval.PuppetObject).Get(`string`)
+		fmt.Println(v.PType().Name(), s)
+
+		lookup.Lookup(impl.NewInvocation(c), `second`, nil, nil)
+		return nil
+	}))
+	// Output:
+	// Test::Hash one
+	// Type mismatch:  value for key 'second' expects an Integer value, got String
+}
+
+func ExampleLookup_keyTypesDefault() {
+	typedOptions := map[string]eval.Value{
+		`path`: options[`path`],
+		impl.KeyTypesOptionKey: types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{
+			`port`: `Integer[1,65535]`})}
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, typedOptions, func(c eval.Context) error {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `port`, types.WrapInteger(8080), nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `port`, eval.UNDEF, nil))
+		lookup.Lookup(impl.NewInvocation(c), `port`, types.WrapString(`http`), nil)
+		return nil
+	}))
+	// Output:
+	// 8080
+	// undef
+	// Type mismatch:  value for key 'port' expects an Integer value, got String
+}
+
 func ExampleLookup_interpolateScope() {
 	eval.Puppet.DoWithParent(context.Background(), func(c eval.Context) {
 		c.DoWithScope(evalimpl.NewScope2(types.WrapStringToInterfaceMap(c, issue.H{
@@ -66,6 +179,188 @@ func ExampleLookup_interpolateScope() {
 	// hello cruel world
 }
 
+func ExampleLookup_interpolateScopeRecursive() {
+	lookupWithScope := func(scope issue.H) {
+		fmt.Println(eval.Puppet.TryWithParent(context.Background(), func(c eval.Context) error {
+			c.DoWithScope(evalimpl.NewScope2(types.WrapStringToInterfaceMap(c, scope), false), func() {
+				lookup.DoWithParent(c, provider.Yaml, options, func(c eval.Context) {
+					fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipScope`, nil, nil))
+				})
+			})
+			return nil
+		}))
+	}
+	lookupWithScope(issue.H{`world`: `%{planet} and %{moon}`, `planet`: `earth`, `moon`: `%{planet}'s moon`})
+	lookupWithScope(issue.H{`world`: `%{facts.bucket}`, `facts`: map[string]interface{}{`region`: `eu`, `bucket`: `b-%{facts.region}`}})
+	lookupWithScope(issue.H{`world`: `%{planet}`, `planet`: `%{world}`})
+	// Output:
+	// hello earth and earth's moon
+	// <nil>
+	// hello b-eu
+	// <nil>
+	// Recursive lookup detected in [ipScope, scope('world'), scope('planet')]
+}
+
+func ExampleLookup_interpolateScopeIndex() {
+	eval.Puppet.DoWithParent(context.Background(), func(c eval.Context) {
+		c.DoWithScope(evalimpl.NewScope2(types.WrapStringToInterfaceMap(c, issue.H{
+			`facts`: map[string]interface{}{
+				`interfaces`: []interface{}{
+					map[string]interface{}{`name`: `lo`, `ip`: `127.0.0.1`},
+					map[string]interface{}{`name`: `eth0`, `ip`: `10.0.0.1`}}},
+		}), false), func() {
+			lookup.DoWithParent(c, provider.Yaml, options, func(c eval.Context) {
+				fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipFactIndex`, nil, nil))
+				fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipFactOutOfRange`, nil, nil))
+			})
+		})
+	})
+	// Output:
+	// ip 10.0.0.1
+	// ip
+}
+
+type netInterface struct {
+	Name    string `hiera:"name"`
+	IP      string `hiera:"ip"`
+	netmask string
+}
+
+type nodeFacts struct {
+	Interfaces []netInterface `hiera:"interfaces"`
+	Secret     string         `hiera:"-"`
+}
+
+func ExampleToMap() {
+	eval.Puppet.DoWithParent(context.Background(), func(c eval.Context) {
+		scope := struct {
+			Facts *nodeFacts `hiera:"facts"`
+		}{&nodeFacts{
+			Interfaces: []netInterface{{`lo`, `127.0.0.1`, `255.0.0.0`}, {`eth0`, `10.0.0.1`, `255.255.255.0`}},
+			Secret:     `hidden`}}
+
+		fmt.Println(impl.ToMap(c, scope))
+		c.DoWithScope(evalimpl.NewScope2(impl.ToMap(c, scope), false), func() {
+			lookup.DoWithParent(c, provider.Yaml, options, func(c eval.Context) {
+				fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipFactIndex`, nil, nil))
+			})
+		})
+	})
+	// Output:
+	// {'facts' => {'interfaces' => [{'name' => 'lo', 'ip' => '127.0.0.1'}, {'name' => 'eth0', 'ip' => '10.0.0.1'}]}}
+	// ip 10.0.0.1
+}
+
+func ExampleLookup_interpolateChangeCase() {
+	eval.Puppet.DoWithParent(context.Background(), func(c eval.Context) {
+		c.DoWithScope(evalimpl.NewScope2(types.WrapStringToInterfaceMap(c, issue.H{
+			`facts`: map[string]interface{}{`host`: `Web01.Example.COM`, `env`: `prod`, `name`: `éLODIE`},
+		}), false), func() {
+			lookup.DoWithParent(c, provider.Yaml, options, func(c eval.Context) {
+				fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipDowncase`, nil, nil))
+				fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipUpcase`, nil, nil))
+				fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipCapitalize`, nil, nil))
+			})
+		})
+	})
+	// Output:
+	// host web01.example.com
+	// PROD-ÉTÉ
+	// Élodie
+}
+
+func ExampleLookup_interpolateJoin() {
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) error {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipJoin`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipJoinDefault`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipJoinScalar`, nil, nil))
+		lookup.Lookup(impl.NewInvocation(c), `ipJoinTooMany`, nil, nil)
+		return nil
+	}))
+	// Output:
+	// one, two, three
+	// [one,two,three]
+	// value of first
+	// Interpolation method 'lookup' takes only one argument
+}
+
+func ExampleLookup_interpolateCustomDelimiters() {
+	sampleData := map[string]string{
+		`world`:    `cruel world`,
+		`greeting`: `hello ${lookup('world')}`,
+		`template`: `hello %{world}`}
+
+	tp := func(ic lookup.ProviderContext, key string, _ map[string]eval.Value) (eval.Value, bool) {
+		v, ok := sampleData[key]
+		return types.WrapString(v), ok
+	}
+
+	delimiterOptions := map[string]eval.Value{
+		impl.InterpolationPrefixOptionKey: types.WrapString(`${`),
+		impl.InterpolationSuffixOptionKey: types.WrapString(`}`)}
+
+	lookup.DoWithParent(context.Background(), tp, delimiterOptions, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `greeting`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `template`, nil, nil))
+	})
+	// Output:
+	// hello cruel world
+	// hello %{world}
+}
+
+func ExampleLookup_interpolateNonASCIIDelimiter() {
+	sampleData := map[string]string{
+		`world`:    `cruel world`,
+		`greeting`: `hello §{lookup('world')}`,
+		`escaped`:  `hello §§{world}`}
+
+	tp := func(ic lookup.ProviderContext, key string, _ map[string]eval.Value) (eval.Value, bool) {
+		v, ok := sampleData[key]
+		return types.WrapString(v), ok
+	}
+
+	delimiterOptions := map[string]eval.Value{
+		impl.InterpolationPrefixOptionKey: types.WrapString(`§{`),
+		impl.InterpolationSuffixOptionKey: types.WrapString(`}`)}
+
+	lookup.DoWithParent(context.Background(), tp, delimiterOptions, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `greeting`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `escaped`, nil, nil))
+	})
+	// Output:
+	// hello cruel world
+	// hello §{world}
+}
+
+func ExampleLookup_interpolateDefault() {
+	sampleData := map[string]string{
+		`zone`:      `zone-a`,
+		`region`:    `%{region || "us-east"}`,
+		`zoned`:     `%{scope('zone') || lookup('zone')}`,
+		`chained`:   `%{missing || lookup('missing') || 'last resort'}`,
+		`undefined`: `[%{lookup('missing')}]`,
+		`quoted`:    `%{literal('a || b')}`}
+
+	tp := func(ic lookup.ProviderContext, key string, _ map[string]eval.Value) (eval.Value, bool) {
+		v, ok := sampleData[key]
+		return types.WrapString(v), ok
+	}
+
+	lookup.DoWithParent(context.Background(), tp, nil, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `region`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `zoned`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `chained`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `undefined`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `quoted`, nil, nil))
+	})
+	// Output:
+	// us-east
+	// zone-a
+	// last resort
+	// [undef]
+	// a || b
+}
+
 func ExampleLookup_interpolateEmpty() {
 	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
 		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `empty1`, nil, nil))
@@ -91,6 +386,16 @@ func ExampleLookup_interpolateLiteral() {
 	// Output: some literal text
 }
 
+func ExampleLookup_interpolateEscaped() {
+	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipEscaped`, nil, options))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `ipLiteralPrefix`, nil, options))
+	})
+	// Output:
+	// a literal %{first} and value of first
+	// a literal %{
+}
+
 func ExampleLookup_interpolateAlias() {
 	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
 		v := lookup.Lookup(impl.NewInvocation(c), `ipAlias`, nil, options)
@@ -115,6 +420,69 @@ func ExampleLookup_interpolateBadFunction() {
 	// Output: Unknown interpolation method 'bad'
 }
 
+func ExampleLookup_malformedYaml() {
+	malformed := map[string]eval.Value{`path`: types.WrapString(`./testdata/malformed.yaml`)}
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, malformed, func(c eval.Context) error {
+		lookup.Lookup(impl.NewInvocation(c), `first`, nil, malformed)
+		return nil
+	}))
+	// Output: Provider 'yaml_data' failed to parse './testdata/malformed.yaml': yaml: line 2: did not find expected ',' or ']'
+}
+
+func ExampleLookup_maxFileSize() {
+	limited := map[string]eval.Value{
+		`path`:                    types.WrapString(`./testdata/sample_data.yaml`),
+		impl.MaxFileSizeOptionKey: types.WrapInteger(64)}
+	err := lookup.TryWithParent(context.Background(), provider.Yaml, limited, func(c eval.Context) error {
+		lookup.Lookup(impl.NewInvocation(c), `first`, nil, limited)
+		return nil
+	})
+	fmt.Println(strings.Contains(err.Error(), `testdata/sample_data.yaml' is `), strings.HasSuffix(strings.TrimSpace(err.Error()), `exceeds the maximum of 64 bytes`))
+	// Output: true true
+}
+
+func ExampleLookup_duplicateKeys() {
+	lenient := map[string]eval.Value{`path`: types.WrapString(`./testdata/duplicates.yaml`)}
+	lookup.DoWithParent(context.Background(), provider.Yaml, lenient, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `first`, nil, lenient))
+	})
+
+	strict := map[string]eval.Value{
+		`path`:                 lenient[`path`],
+		`allow_duplicate_keys`: types.WrapBoolean(false)}
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, strict, func(c eval.Context) error {
+		lookup.Lookup(impl.NewInvocation(c), `first`, nil, strict)
+		return nil
+	}))
+	// Output:
+	// last value of first
+	// Provider 'yaml_data' failed to parse './testdata/duplicates.yaml': yaml: unmarshal errors:
+	//   line 3: key "first" already set in map
+}
+
+func ExampleLookup_duplicateKeysAfterLenient() {
+	lenient := map[string]eval.Value{
+		`path`:                  types.WrapString(`./testdata/duplicates.yaml`),
+		impl.FileCacheOptionKey: types.WrapRuntime(impl.NewConcurrentMap(7))}
+	strict := map[string]eval.Value{
+		`path`:                  lenient[`path`],
+		impl.FileCacheOptionKey: lenient[impl.FileCacheOptionKey],
+		`allow_duplicate_keys`:  types.WrapBoolean(false)}
+
+	// The file is parsed leniently first and the result is kept in the shared file cache
+	lookup.DoWithParent(context.Background(), provider.Yaml, lenient, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `first`, nil, nil))
+	})
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, strict, func(c eval.Context) error {
+		lookup.Lookup(impl.NewInvocation(c), `first`, nil, nil)
+		return nil
+	}))
+	// Output:
+	// last value of first
+	// Provider 'yaml_data' failed to parse './testdata/duplicates.yaml': yaml: unmarshal errors:
+	//   line 3: key "first" already set in map
+}
+
 func ExampleLookup_notFoundWithoutDefault() {
 	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) error {
 		lookup.Lookup(impl.NewInvocation(c), `nonexistent`, nil, options)
@@ -160,6 +528,90 @@ func ExampleLookup_badIntDig() {
 	// Output: lookup() Got Hash[Enum, Data] when a hash-like object was expected to access value using '3' from key 'hash.3'
 }
 
+func ExampleFind() {
+	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
+		fmt.Println(lookup.Find(impl.NewInvocation(c), `first`, nil))
+		fmt.Println(lookup.Find(impl.NewInvocation(c), `nullentry`, nil))
+		fmt.Println(lookup.Find(impl.NewInvocation(c), `nonexistent`, nil))
+	})
+	// Output:
+	// value of first true
+	// undef true
+	// <nil> false
+}
+
+func ExampleLookupBatch() {
+	typedOptions := map[string]eval.Value{
+		`path`: options[`path`],
+		impl.KeyTypesOptionKey: types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{
+			`second`: `Integer`})}
+	lookup.DoWithParent(context.Background(), provider.Yaml, typedOptions, func(c eval.Context) {
+		r := lookup.LookupBatch(impl.NewInvocation(c), []string{`first`, `second`, `nonexistent`, `ipBad`, `nullentry`}, nil)
+		fmt.Println(r.Values)
+		for _, ke := range r.Errors {
+			fmt.Printf("%s: %s\n", ke.Key, strings.TrimSpace(ke.Error.Error()))
+		}
+		fmt.Println(r.NotFound)
+	})
+	// Output:
+	// {'first' => 'value of first', 'nullentry' => undef}
+	// second: Type mismatch:  value for key 'second' expects an Integer value, got String
+	// ipBad: Unknown interpolation method 'bad'
+	// [nonexistent]
+}
+
+func ExampleLookupString() {
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) error {
+		fmt.Println(lookup.LookupString(impl.NewInvocation(c), `first`, `default`))
+		fmt.Println(lookup.LookupString(impl.NewInvocation(c), `nonexistent`, `default`))
+		lookup.LookupString(impl.NewInvocation(c), `array`, `default`)
+		return nil
+	}))
+	// Output:
+	// value of first
+	// default
+	// Type mismatch:  value for key 'array' expects a String value, got Tuple
+}
+
+func ExampleLookupInt() {
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) error {
+		fmt.Println(lookup.LookupInt(impl.NewInvocation(c), `hash.int`, 42))
+		fmt.Println(lookup.LookupInt(impl.NewInvocation(c), `nonexistent`, 42))
+		lookup.LookupInt(impl.NewInvocation(c), `first`, 42)
+		return nil
+	}))
+	// Output:
+	// 1
+	// 42
+	// Type mismatch:  value for key 'first' expects an Integer value, got String
+}
+
+func ExampleLookupBool() {
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) error {
+		fmt.Println(lookup.LookupBool(impl.NewInvocation(c), `enabled`, false))
+		fmt.Println(lookup.LookupBool(impl.NewInvocation(c), `nullentry`, true))
+		lookup.LookupBool(impl.NewInvocation(c), `hash.int`, false)
+		return nil
+	}))
+	// Output:
+	// true
+	// true
+	// Type mismatch:  value for key 'hash.int' expects a Boolean value, got Integer
+}
+
+func ExampleLookupStringSlice() {
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) error {
+		fmt.Println(lookup.LookupStringSlice(impl.NewInvocation(c), `hash.array`, nil))
+		fmt.Println(lookup.LookupStringSlice(impl.NewInvocation(c), `nonexistent`, []string{`a`, `b`}))
+		lookup.LookupStringSlice(impl.NewInvocation(c), `hash`, nil)
+		return nil
+	}))
+	// Output:
+	// [two value of first]
+	// [a b]
+	// Type mismatch:  value for key 'hash' expects an Array value, got Struct
+}
+
 func ExampleLookup2_findFirst() {
 	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
 		fmt.Println(lookup.Lookup2(impl.NewInvocation(c), []string{`first`, `second`}, types.DefaultAnyType(), nil, nil, nil, options, nil))
@@ -189,7 +641,97 @@ func ExampleLookup2_notFoundDflt() {
 	// Output: default value
 }
 
-func ExampleContextCachedValue() {
+func ExampleLookup_negativeCache() {
+	queryCount := 0
+	countingProvider := func(ic lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+		queryCount++
+		return provider.Yaml(ic, key, options)
+	}
+
+	lookupTwice := func(ttl eval.Value) {
+		queryCount = 0
+		ttlOptions := map[string]eval.Value{`path`: options[`path`]}
+		if ttl != nil {
+			ttlOptions[impl.NegativeCacheTTLOptionKey] = ttl
+		}
+		lookup.DoWithParent(context.Background(), countingProvider, ttlOptions, func(c eval.Context) {
+			for i := 0; i < 2; i++ {
+				lookup.Lookup(impl.NewInvocation(c), `nonexistent`, eval.UNDEF, nil)
+				if i == 0 && ttl != nil {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		})
+		fmt.Println(queryCount)
+	}
+
+	lookupTwice(nil)
+	lookupTwice(types.WrapInteger(60))
+	lookupTwice(types.WrapFloat(0.001))
+	// Output:
+	// 2
+	// 1
+	// 2
+}
+
+func ExampleEnvData() {
+	os.Setenv(`EXAMPLE_ENVDATA_DB_HOST`, `db.example.com`)
+	os.Setenv(`EXAMPLE_ENVDATA_DB_PORT`, `5432`)
+	os.Setenv(`EXAMPLE_ENVDATA_REGION`, `eu-west-1`)
+	defer func() {
+		os.Unsetenv(`EXAMPLE_ENVDATA_DB_HOST`)
+		os.Unsetenv(`EXAMPLE_ENVDATA_DB_PORT`)
+		os.Unsetenv(`EXAMPLE_ENVDATA_REGION`)
+	}()
+
+	envOptions := map[string]eval.Value{`prefix`: types.WrapString(`EXAMPLE_ENVDATA_`)}
+	lookup.DoWithParent(context.Background(), provider.EnvData, envOptions, func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `region`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `db.host`, nil, nil))
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `db`, nil, nil))
+		fmt.Println(lookup.Find(impl.NewInvocation(c), `unset`, nil))
+	})
+	// Output:
+	// eu-west-1
+	// db.example.com
+	// {'host' => 'db.example.com', 'port' => '5432'}
+	// <nil> false
+}
+
+func ExampleHoconData() {
+	hoconProvider := func(ic lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+		return provider.HoconData(ic, options).Get4(key)
+	}
+	hoconOptions := func(path string) map[string]eval.Value {
+		return map[string]eval.Value{`path`: types.WrapString(path)}
+	}
+
+	lookup.DoWithParent(context.Background(), hoconProvider, hoconOptions(`./testdata/hocon/app.conf`), func(c eval.Context) {
+		ic := impl.NewInvocation(c)
+		fmt.Println(lookup.Lookup(ic, `app.bin`, nil, nil))
+		fmt.Println(lookup.Lookup(ic, `db.url`, nil, nil))
+		fmt.Println(lookup.Lookup(ic, `db.pool`, nil, nil))
+		fmt.Println(lookup.Lookup(ic, `db.timeout`, nil, nil))
+		fmt.Println(lookup.Lookup(ic, `listeners`, nil, nil))
+	})
+	lookup.DoWithParent(context.Background(), hoconProvider, hoconOptions(`./testdata/hocon/missing.conf`), func(c eval.Context) {
+		fmt.Println(lookup.Lookup(impl.NewInvocation(c), `app`, types.WrapString(`not found`), nil))
+	})
+	fmt.Println(lookup.TryWithParent(context.Background(), hoconProvider, hoconOptions(`./testdata/hocon/malformed.conf`), func(c eval.Context) error {
+		lookup.Lookup(impl.NewInvocation(c), `app`, nil, nil)
+		return nil
+	}))
+	// Output:
+	// /opt/demo/bin
+	// jdbc:postgresql://localhost:5432/demo
+	// {'max' => 10, 'min' => 2}
+	// 30s
+	// ['http', 'https']
+	// not found
+	// Provider 'hocon_data' failed to parse './testdata/hocon/malformed.conf': line 2: unterminated string
+}
+
+func ExampleProviderContext_cachedValue() {
 
 	cachingProvider := func(ic lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
 		if v, ok := ic.CachedValue(key); ok {
@@ -223,6 +765,70 @@ func ExampleContextCachedValue() {
 	// generated value for scope 'b'
 }
 
+func ExampleProviderContext_cachedFileData() {
+	parseCount := 0
+	fileProvider := func(ic lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+		data, _ := lookup.CachedFileData(ic, options[`path`].String(), func(content []byte) eval.Value {
+			parseCount++
+			return impl.UnmarshalYaml(ic.Invocation(), content)
+		})
+		return data.(eval.OrderedMap).Get4(key)
+	}
+
+	sharedOptions := map[string]eval.Value{
+		`path`:                  options[`path`],
+		impl.FileCacheOptionKey: types.WrapRuntime(impl.NewConcurrentMap(7))}
+
+	for i := 0; i < 2; i++ {
+		lookup.DoWithParent(context.Background(), fileProvider, sharedOptions, func(c eval.Context) {
+			fmt.Println(lookup.Lookup(impl.NewInvocation(c), `first`, nil, nil))
+			fmt.Println(lookup.Lookup(impl.NewInvocation(c), `hash.string`, nil, nil))
+		})
+	}
+	fmt.Println(parseCount)
+	// Output:
+	// value of first
+	// one
+	// value of first
+	// one
+	// 1
+}
+
+func ExampleHoconData_includeTooLarge() {
+	hoconProvider := func(ic lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+		return provider.HoconData(ic, options).Get4(key)
+	}
+	options := map[string]eval.Value{
+		`path`:                   types.WrapString(`./testdata/hocon/small.conf`),
+		impl.MaxFileSizeOptionKey: types.WrapInteger(40)}
+
+	err := lookup.TryWithParent(context.Background(), hoconProvider, options, func(c eval.Context) error {
+		lookup.Lookup(impl.NewInvocation(c), `db`, nil, nil)
+		return nil
+	})
+	fmt.Println(strings.Contains(err.Error(), `testdata/hocon/defaults.conf' is `), strings.HasSuffix(strings.TrimSpace(err.Error()), `exceeds the maximum of 40 bytes`))
+	// Output: true true
+}
+
+func ExampleGetCacheStats() {
+	sharedOptions := map[string]eval.Value{
+		`path`:                  options[`path`],
+		impl.FileCacheOptionKey: types.WrapRuntime(impl.NewConcurrentMap(7))}
+
+	for i := 0; i < 2; i++ {
+		lookup.DoWithParent(context.Background(), provider.Yaml, sharedOptions, func(c eval.Context) {
+			lookup.Lookup(impl.NewInvocation(c), `first`, nil, nil)
+			lookup.Lookup(impl.NewInvocation(c), `first`, nil, nil)
+			lookup.Lookup(impl.NewInvocation(c), `hash.string`, nil, nil)
+			lookup.Lookup(impl.NewInvocation(c), `nonexistent`, eval.UNDEF, nil)
+			fmt.Printf("%+v\n", impl.GetCacheStats(c))
+		})
+	}
+	// Output:
+	// {FileHits:0 FileMisses:1 TopProviderHits:2 TopProviderMisses:3}
+	// {FileHits:1 FileMisses:0 TopProviderHits:2 TopProviderMisses:3}
+}
+
 func ExampleLookup_dottedStringInt() {
 	lookup.DoWithParent(context.Background(), provider.Yaml, options, func(c eval.Context) {
 		v := lookup.Lookup(impl.NewInvocation(c), `hash.array.0`, nil, options)
@@ -249,4 +855,27 @@ func ExampleLookup_mapProvider() {
 	// Output:
 	// value of a
 	// value of b
-}
\ No newline at end of file
+}
+
+func ExampleNewResolvedConfig() {
+	common := hieratest.NewDataProvider(`common`, map[string]interface{}{
+		`greeting`: `hello %{name}`})
+
+	lookup.DoWithParent(context.Background(), provider.Yaml, hieratest.Options(common), func(c eval.Context) {
+		c.DoWithScope(evalimpl.NewScope2(types.WrapStringToInterfaceMap(c, issue.H{`name`: `world`}), false), func() {
+			ic := impl.NewInvocation(c)
+
+			// The configuration resolves to the injected providers. No file is read.
+			for _, dp := range impl.ResolvedConfig(ic, `/etc/hiera/hiera.yaml`).Hierarchy() {
+				fmt.Println(dp.FullName())
+				fmt.Println(impl.CheckedLookup(dp, impl.NewKey(`greeting`), ic, nil))
+				fmt.Println(impl.CheckedLookup(dp, impl.NewKey(`farewell`), ic, nil))
+			}
+		})
+	})
+
+	// Output:
+	// in-memory provider 'common'
+	// hello world true
+	// <nil> false
+}
@@ -16,11 +16,25 @@ func CheckedLookup(dp lookup.DataProvider, key lookup.Key, invocation lookup.Inv
 type basicProvider struct {
 	function config.Function
 
+	// The hierarchy entry that the provider was created from. It must be set by the constructors below
+	// once they are implemented. Until then, %{hiera_entry} is only set for providers that are created
+	// with it, e.g. in tests.
+	hierEntry config.HierarchyEntry
+
 	// Set if the designated function has a return type that is equal to or more
 	// strict than RichData.
 	valueIsValidated bool
 }
 
+// A hierarchyProvider is a data provider that was created from a hierarchy entry
+type hierarchyProvider interface {
+	hierarchyEntry() config.HierarchyEntry
+}
+
+func (dh *basicProvider) hierarchyEntry() config.HierarchyEntry {
+	return dh.hierEntry
+}
+
 type dataHashProvider struct {
 	basicProvider
 	locations []lookup.Location
@@ -68,6 +82,9 @@ func (dh *dataHashProvider) dataValue(invocation lookup.Invocation, location loo
 		}
 		return msg
 	})
+	if booleanOption(invocation, RawOptionKey) {
+		return value, true
+	}
 	return Interpolate(invocation, value, true), true
 }
 
@@ -92,16 +109,16 @@ func (dh *dataHashProvider) FullName() string {
 }
 
 func newDataHashProvider(ic lookup.Invocation, he config.HierarchyEntry) lookup.DataProvider {
-	// TODO
+	// TODO, including setting basicProvider.hierEntry to he
 	return nil
 }
 
 func newDataDigProvider(ic lookup.Invocation, he config.HierarchyEntry) lookup.DataProvider {
-	// TODO
+	// TODO, including setting basicProvider.hierEntry to he
 	return nil
 }
 
 func newLookupKeyProvider(ic lookup.Invocation, he config.HierarchyEntry) lookup.DataProvider {
-	// TODO
+	// TODO, including setting basicProvider.hierEntry to he
 	return nil
 }
@@ -0,0 +1,26 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+func TestInvocation_WithDataProvider_hieraEntry(t *testing.T) {
+	withFacts(t, map[string]interface{}{}, func(ic *invocation) {
+		interpolate := func() string {
+			return Interpolate(ic, types.WrapString(`from %{hiera_entry}`), true).String()
+		}
+		dp := &dataHashProvider{basicProvider: basicProvider{hierEntry: &hierEntry{name: `Common`}}}
+		ic.WithDataProvider(dp, func() (eval.Value, bool) {
+			if s := interpolate(); s != `from Common` {
+				t.Errorf(`expected the name of the hierarchy entry, got '%s'`, s)
+			}
+			return nil, false
+		})
+		if s := interpolate(); s != `from ` {
+			t.Errorf(`expected an empty name outside of a provider read, got '%s'`, s)
+		}
+	})
+}
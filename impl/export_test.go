@@ -0,0 +1,4 @@
+package impl
+
+// UnregisterLocationKind makes unregisterLocationKind available to the examples
+var UnregisterLocationKind = unregisterLocationKind
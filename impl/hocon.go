@@ -0,0 +1,739 @@
+package impl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+// UnmarshalHoconFile parses the HOCON (Human-Optimized Config Object Notation) data that was read from the
+// file at the given path and returns the resulting hash. The supported subset covers objects, arrays, and
+// scalars using both JSON and the relaxed HOCON syntax, i.e. comments, unquoted keys and strings, dotted key
+// paths, optional commas, and merging of duplicate object keys. It also covers value concatenation,
+// triple-quoted strings, includes, and substitutions.
+//
+// An included file is resolved relative to the directory of the file that includes it. It is ignored when
+// it doesn't exist unless the include is declared as required(). Like any other data file, it must not be
+// larger than the maximum file size.
+//
+// A substitution is resolved against the root of the parsed data and falls back to the environment variable
+// with the same name. An optional substitution, ${?name}, that cannot be resolved leaves the field undefined.
+// A substitution of the field that it is assigned to, e.g. path = ${path}":/bin", resolves to the previous
+// value of that field, or to the environment variable when the field has no previous value.
+//
+// A parse error will name the given provider and the path of the file.
+func UnmarshalHoconFile(c eval.Context, provider, path string, data []byte) eval.OrderedMap {
+	ic, ok := c.(lookup.Invocation)
+	if !ok {
+		ic = NewInvocation(c)
+	}
+	v, err := unmarshalHocon(ic, path, data)
+	if err != nil {
+		panic(eval.Error(HIERA_DATA_FILE_PARSE_ERROR, issue.H{`provider`: provider, `path`: path, `detail`: err.Error()}))
+	}
+	return v
+}
+
+// hoconText is a quoted or unquoted string. An unquoted string that appears on its own is interpreted
+// as a boolean, null, or number when possible.
+type hoconText struct {
+	text   string
+	quoted bool
+}
+
+// hoconSubst is a ${path} or ${?path} substitution
+type hoconSubst struct {
+	path     []string
+	optional bool
+	line     int
+}
+
+// hoconConcat is a value concatenation, e.g. ${home}"/bin"
+type hoconConcat []interface{}
+
+// hoconField is a value that replaced the previous value of the same field. A self-referential
+// substitution, e.g. the ${path} in path = ${path}":/bin", resolves to the previous value.
+type hoconField struct {
+	value    interface{}
+	previous interface{}
+}
+
+// fieldValue returns the current value of the given field value
+func fieldValue(v interface{}) interface{} {
+	if f, ok := v.(*hoconField); ok {
+		return f.value
+	}
+	return v
+}
+
+// replaceField returns a field value where the given value replaces the given previous value. When the
+// given value already replaces values of its own, the previous value is placed last in that chain.
+func replaceField(previous, v interface{}) *hoconField {
+	if f, ok := v.(*hoconField); ok {
+		return &hoconField{f.value, replaceField(previous, f.previous)}
+	}
+	return &hoconField{v, previous}
+}
+
+type hoconObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newHoconObject() *hoconObject {
+	return &hoconObject{values: make(map[string]interface{})}
+}
+
+// set assigns the value to the given key path. Intermediate objects are created as needed. An object
+// that is assigned to a key that already holds an object is merged into that object. Any other value
+// replaces the value that the key holds but the replaced value is retained as its previous value.
+func (o *hoconObject) set(path []string, v interface{}) {
+	key := path[0]
+	old, exists := o.values[key]
+	if !exists {
+		o.keys = append(o.keys, key)
+	}
+	if len(path) > 1 {
+		child, ok := fieldValue(old).(*hoconObject)
+		if !ok {
+			child = newHoconObject()
+			o.assign(key, old, exists, child)
+		}
+		child.set(path[1:], v)
+		return
+	}
+	if oo, ok := fieldValue(old).(*hoconObject); ok {
+		if no, ok := v.(*hoconObject); ok {
+			oo.merge(no)
+			return
+		}
+	}
+	o.assign(key, old, exists, v)
+}
+
+func (o *hoconObject) assign(key string, old interface{}, exists bool, v interface{}) {
+	if exists {
+		v = replaceField(old, v)
+	}
+	o.values[key] = v
+}
+
+func (o *hoconObject) merge(other *hoconObject) {
+	for _, k := range other.keys {
+		o.set([]string{k}, other.values[k])
+	}
+}
+
+type hoconError string
+
+func (e hoconError) Error() string {
+	return string(e)
+}
+
+type hoconParser struct {
+	ic        lookup.Invocation
+	path      string
+	data      string
+	pos       int
+	including []string
+}
+
+func unmarshalHocon(ic lookup.Invocation, path string, data []byte) (result eval.OrderedMap, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if he, ok := r.(hoconError); ok {
+				err = he
+				return
+			}
+			panic(r)
+		}
+	}()
+	abs, _ := filepath.Abs(path)
+	root := (&hoconParser{ic: ic, path: path, data: string(data), including: []string{abs}}).parseRoot()
+	r := &hoconResolver{root: root}
+	v, _ := r.resolve(root, []string{})
+	return v.(eval.OrderedMap), nil
+}
+
+func (p *hoconParser) fail(format string, args ...interface{}) {
+	line := p.line()
+	msg := fmt.Sprintf(format, args...)
+	if len(p.including) > 1 {
+		panic(hoconError(fmt.Sprintf(`included file '%s' line %d: %s`, p.path, line, msg)))
+	}
+	panic(hoconError(fmt.Sprintf(`line %d: %s`, line, msg)))
+}
+
+func (p *hoconParser) line() int {
+	return strings.Count(p.data[:p.pos], "\n") + 1
+}
+
+func (p *hoconParser) atEnd() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *hoconParser) peek() byte {
+	if p.atEnd() {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *hoconParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.data[p.pos:], s)
+}
+
+func (p *hoconParser) atComment() bool {
+	return p.peek() == '#' || p.hasPrefix(`//`)
+}
+
+// skipSpace skips whitespace and comments up to, but not including, the next newline
+func (p *hoconParser) skipSpace() {
+	for !p.atEnd() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case p.atComment():
+			for !p.atEnd() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// skipSpaceAndNewlines skips whitespace, newlines, and comments
+func (p *hoconParser) skipSpaceAndNewlines() {
+	for {
+		p.skipSpace()
+		if p.peek() != '\n' {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *hoconParser) parseRoot() *hoconObject {
+	p.skipSpaceAndNewlines()
+	var root *hoconObject
+	if p.peek() == '{' {
+		p.pos++
+		root = p.parseObjectBody('}')
+		p.skipSpaceAndNewlines()
+	} else {
+		root = p.parseObjectBody(0)
+	}
+	if !p.atEnd() {
+		p.fail(`unexpected '%c' after the root object`, p.peek())
+	}
+	return root
+}
+
+// parseObjectBody parses the fields of an object up to and including the given end character. An end
+// character of zero denotes the end of the data.
+func (p *hoconParser) parseObjectBody(end byte) *hoconObject {
+	obj := newHoconObject()
+	for {
+		p.skipSpaceAndNewlines()
+		if p.atEnd() {
+			if end != 0 {
+				p.fail(`expected '%c'`, end)
+			}
+			return obj
+		}
+		if end != 0 && p.peek() == end {
+			p.pos++
+			return obj
+		}
+		if p.atInclude() {
+			obj.merge(p.parseInclude())
+		} else {
+			key := p.parseKey()
+			p.skipSpace()
+			switch {
+			case p.peek() == '{':
+			case p.peek() == ':' || p.peek() == '=':
+				p.pos++
+				p.skipSpaceAndNewlines()
+			case p.hasPrefix(`+=`):
+				p.fail(`the '+=' operator is not supported`)
+			default:
+				p.fail(`expected ':' or '=' after key '%s'`, strings.Join(key, `.`))
+			}
+			obj.set(key, p.parseValue())
+		}
+		p.endOfElement(end)
+	}
+}
+
+// endOfElement consumes the separator that follows an object field or an array element
+func (p *hoconParser) endOfElement(end byte) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == ',' || c == '\n':
+		p.pos++
+	case p.atEnd() || end != 0 && c == end:
+	default:
+		p.fail(`unexpected '%c'`, c)
+	}
+}
+
+func (p *hoconParser) parseArray() []interface{} {
+	elements := make([]interface{}, 0)
+	for {
+		p.skipSpaceAndNewlines()
+		if p.atEnd() {
+			p.fail(`expected ']'`)
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return elements
+		}
+		elements = append(elements, p.parseValue())
+		p.endOfElement(']')
+	}
+}
+
+// parseKey parses a key path, i.e. a dot separated sequence of quoted or unquoted segments
+func (p *hoconParser) parseKey() []string {
+	var path []string
+	for {
+		switch {
+		case p.peek() == '"':
+			path = append(path, p.parseQuoted())
+		default:
+			s := p.parseUnquoted(true)
+			if s == `` {
+				if p.atEnd() {
+					p.fail(`expected a key`)
+				}
+				p.fail(`unexpected '%c' in key`, p.peek())
+			}
+			path = append(path, s)
+		}
+		if p.peek() != '.' {
+			return path
+		}
+		p.pos++
+	}
+}
+
+// parseValue parses a value that extends to the end of the line, the next comma, or the end of the
+// enclosing object or array. Several adjacent values form a concatenation.
+func (p *hoconParser) parseValue() interface{} {
+	var parts hoconConcat
+	for {
+		var v interface{}
+		switch c := p.peek(); {
+		case c == '{':
+			p.pos++
+			v = p.parseObjectBody('}')
+		case c == '[':
+			p.pos++
+			v = p.parseArray()
+		case p.hasPrefix(`"""`):
+			v = hoconText{p.parseTripleQuoted(), true}
+		case c == '"':
+			v = hoconText{p.parseQuoted(), true}
+		case p.hasPrefix(`${`):
+			v = p.parseSubstitution()
+		default:
+			s := p.parseUnquoted(false)
+			if s == `` {
+				if p.atEnd() {
+					p.fail(`expected a value`)
+				}
+				p.fail(`unexpected '%c'`, c)
+			}
+			v = hoconText{s, false}
+		}
+		parts = append(parts, v)
+
+		start := p.pos
+		for p.peek() == ' ' || p.peek() == '\t' {
+			p.pos++
+		}
+		ws := p.data[start:p.pos]
+		if c := p.peek(); p.atEnd() || strings.IndexByte("\r\n,}]", c) >= 0 || p.atComment() {
+			break
+		}
+		if ws != `` {
+			parts = append(parts, hoconText{ws, true})
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return parts
+}
+
+// parseUnquoted parses an unquoted string. A dot terminates the string when it is part of a key.
+func (p *hoconParser) parseUnquoted(inKey bool) string {
+	start := p.pos
+	for !p.atEnd() && !p.atComment() {
+		c := p.peek()
+		if strings.IndexByte(" \t\r\n$\"{}[]:=,+#`^?!@*&\\", c) >= 0 || inKey && c == '.' {
+			break
+		}
+		p.pos++
+	}
+	return p.data[start:p.pos]
+}
+
+func (p *hoconParser) parseQuoted() string {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.atEnd() || p.peek() == '\n' {
+			p.fail(`unterminated string`)
+		}
+		c := p.peek()
+		p.pos++
+		switch c {
+		case '"':
+			return b.String()
+		case '\\':
+			if p.atEnd() {
+				p.fail(`unterminated string`)
+			}
+			e := p.peek()
+			p.pos++
+			switch e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.data) {
+					p.fail(`invalid unicode escape`)
+				}
+				r, err := strconv.ParseUint(p.data[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					p.fail(`invalid unicode escape`)
+				}
+				p.pos += 4
+				b.WriteRune(rune(r))
+			default:
+				p.fail(`invalid escape '\%c'`, e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *hoconParser) parseTripleQuoted() string {
+	p.pos += 3
+	end := strings.Index(p.data[p.pos:], `"""`)
+	if end < 0 {
+		p.fail(`unterminated string`)
+	}
+	s := p.data[p.pos : p.pos+end]
+	p.pos += end + 3
+	return s
+}
+
+func (p *hoconParser) parseSubstitution() *hoconSubst {
+	s := &hoconSubst{line: p.line()}
+	p.pos += 2
+	if p.peek() == '?' {
+		s.optional = true
+		p.pos++
+	}
+	p.skipSpace()
+	s.path = p.parseKey()
+	p.skipSpace()
+	if p.peek() != '}' {
+		p.fail(`expected '}' to end the substitution`)
+	}
+	p.pos++
+	return s
+}
+
+func (p *hoconParser) atInclude() bool {
+	if !p.hasPrefix(`include`) {
+		return false
+	}
+	rest := p.data[p.pos+len(`include`):]
+	return rest != `` && (rest[0] == ' ' || rest[0] == '\t')
+}
+
+// parseInclude parses an include statement and returns the object of the included file
+func (p *hoconParser) parseInclude() *hoconObject {
+	p.pos += len(`include`)
+	p.skipSpace()
+	required := false
+	closers := 0
+	for _, fn := range []string{`required(`, `file(`} {
+		if p.hasPrefix(fn) {
+			required = required || fn == `required(`
+			p.pos += len(fn)
+			closers++
+			p.skipSpace()
+		}
+	}
+	if p.peek() != '"' {
+		p.fail(`expected a quoted file name after include`)
+	}
+	name := p.parseQuoted()
+	for ; closers > 0; closers-- {
+		p.skipSpace()
+		if p.peek() != ')' {
+			p.fail(`expected ')'`)
+		}
+		p.pos++
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.path), path)
+	}
+	abs, _ := filepath.Abs(path)
+	for _, ip := range p.including {
+		if ip == abs {
+			p.fail(`file '%s' is included recursively`, name)
+		}
+	}
+	assertFileSize(p.ic, path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return newHoconObject()
+		}
+		p.fail(`unable to include '%s': %s`, name, err.Error())
+	}
+	return (&hoconParser{ic: p.ic, path: path, data: string(data), including: append(p.including, abs)}).parseRoot()
+}
+
+// hoconFrame is a value that is being resolved together with its path
+type hoconFrame struct {
+	name string
+	node interface{}
+}
+
+type hoconResolver struct {
+	root *hoconObject
+
+	// The values that are being resolved, innermost last
+	resolving []hoconFrame
+}
+
+// resolve returns the evaluator value of the given parsed value together with a boolean that is false when
+// the value is undefined because it stems from an optional substitution that could not be resolved. The
+// path is the path of the value in the root object, or nil when the value is an element of an array.
+func (r *hoconResolver) resolve(v interface{}, path []string) (eval.Value, bool) {
+	switch v := v.(type) {
+	case *hoconObject:
+		es := make([]*types.HashEntry, 0, len(v.keys))
+		for _, k := range v.keys {
+			var ev eval.Value
+			var ok bool
+			if path == nil {
+				ev, ok = r.resolve(v.values[k], nil)
+			} else {
+				ev, ok = r.resolveAt(append(path[:len(path):len(path)], k), v.values[k])
+			}
+			if ok {
+				es = append(es, types.WrapHashEntry2(k, ev))
+			}
+		}
+		return types.WrapHash(es), true
+	case *hoconField:
+		return r.resolve(v.value, path)
+	case []interface{}:
+		vs := make([]eval.Value, 0, len(v))
+		for _, e := range v {
+			if ev, ok := r.resolve(e, nil); ok {
+				vs = append(vs, ev)
+			}
+		}
+		return types.WrapValues(vs), true
+	case hoconText:
+		if v.quoted {
+			return types.WrapString(v.text), true
+		}
+		return hoconScalar(v.text), true
+	case *hoconSubst:
+		return r.resolveSubstitution(v)
+	case hoconConcat:
+		return r.resolveConcat(v, path)
+	}
+	panic(hoconError(fmt.Sprintf(`unexpected value %v`, v)))
+}
+
+func (r *hoconResolver) resolveSubstitution(s *hoconSubst) (eval.Value, bool) {
+	if v, ok := r.find(s, r.root, 0); ok {
+		return v, true
+	}
+	name := strings.Join(s.path, `.`)
+	if ev, ok := os.LookupEnv(name); ok {
+		return types.WrapString(ev), true
+	}
+	if s.optional {
+		return nil, false
+	}
+	panic(hoconError(fmt.Sprintf(`line %d: could not resolve substitution ${%s}`, s.line, name)))
+}
+
+// find returns the resolved value at the path of the given substitution, starting with the segment at
+// the given depth of that path in the given value
+func (r *hoconResolver) find(s *hoconSubst, v interface{}, depth int) (eval.Value, bool) {
+	if depth == len(s.path) {
+		return r.resolveGuarded(s, s.path, v)
+	}
+	if o, ok := fieldValue(v).(*hoconObject); ok {
+		if cv, ok := o.values[s.path[depth]]; ok {
+			return r.find(s, cv, depth+1)
+		}
+		return nil, false
+	}
+
+	// A concatenation or a substitution must be resolved before it can be dug into
+	ev, ok := r.resolveGuarded(s, s.path[:depth], v)
+	for ; ok && depth < len(s.path); depth++ {
+		hv, isHash := ev.(*types.HashValue)
+		if !isHash {
+			return nil, false
+		}
+		ev, ok = hv.Get4(s.path[depth])
+	}
+	return ev, ok
+}
+
+// resolveGuarded resolves the value that the given substitution found at the given path. A substitution of
+// the path of the innermost value being resolved is self-referential. It resolves to the previous value of
+// that path, or is undefined when there is no previous value, so that it falls back to the environment. A
+// substitution of any other path that is being resolved is a cycle, which is an error.
+func (r *hoconResolver) resolveGuarded(s *hoconSubst, path []string, v interface{}) (eval.Value, bool) {
+	name := strings.Join(path, `.`)
+	for i := len(r.resolving) - 1; i >= 0; i-- {
+		if r.resolving[i].name != name {
+			continue
+		}
+		if i < len(r.resolving)-1 {
+			panic(hoconError(fmt.Sprintf(`line %d: substitution ${%s} refers to itself`, s.line, strings.Join(s.path, `.`))))
+		}
+		if f, ok := r.resolving[i].node.(*hoconField); ok {
+			return r.resolveAt(path, f.previous)
+		}
+		return nil, false
+	}
+	return r.resolveAt(path, v)
+}
+
+// resolveAt resolves the value at the given path in the root object
+func (r *hoconResolver) resolveAt(path []string, v interface{}) (eval.Value, bool) {
+	r.resolving = append(r.resolving, hoconFrame{strings.Join(path, `.`), v})
+	defer func() { r.resolving = r.resolving[:len(r.resolving)-1] }()
+	return r.resolve(v, path)
+}
+
+// resolveConcat resolves a value concatenation. Objects are merged, arrays are appended, and all other
+// values are joined into a string.
+func (r *hoconResolver) resolveConcat(parts hoconConcat, path []string) (eval.Value, bool) {
+	vs := make([]eval.Value, 0, len(parts))
+	for _, part := range parts {
+		if ev, ok := r.resolve(part, path); ok {
+			vs = append(vs, ev)
+		}
+	}
+	if len(vs) == 0 {
+		return nil, false
+	}
+	switch vs[0].(type) {
+	case *types.HashValue:
+		var result *types.HashValue
+		for _, ev := range vs {
+			if hv, ok := ev.(*types.HashValue); ok {
+				if result == nil {
+					result = hv
+				} else {
+					result = mergeHoconHashes(result, hv)
+				}
+			}
+		}
+		return result, true
+	case *types.ArrayValue:
+		var es []eval.Value
+		for _, ev := range vs {
+			if av, ok := ev.(*types.ArrayValue); ok {
+				es = av.AppendTo(es)
+			}
+		}
+		return types.WrapValues(es), true
+	}
+	var b strings.Builder
+	for _, ev := range vs {
+		switch ev.(type) {
+		case *types.HashValue, *types.ArrayValue:
+			panic(hoconError(fmt.Sprintf(`cannot concatenate %s with a string`, ev.PType().Name())))
+		case *types.UndefValue:
+			b.WriteString(`null`)
+		default:
+			b.WriteString(ev.String())
+		}
+	}
+	return types.WrapString(b.String()), true
+}
+
+// mergeHoconHashes merges b into a. Nested hashes are merged recursively.
+func mergeHoconHashes(a, b *types.HashValue) *types.HashValue {
+	es := make([]*types.HashEntry, 0, a.Len()+b.Len())
+	a.EachPair(func(k, v eval.Value) {
+		if bv, ok := b.Get(k); ok {
+			ah, aok := v.(*types.HashValue)
+			bh, bok := bv.(*types.HashValue)
+			if aok && bok {
+				v = mergeHoconHashes(ah, bh)
+			} else {
+				v = bv
+			}
+		}
+		es = append(es, types.WrapHashEntry(k, v))
+	})
+	b.EachPair(func(k, v eval.Value) {
+		if !a.IncludesKey(k) {
+			es = append(es, types.WrapHashEntry(k, v))
+		}
+	})
+	return types.WrapHash(es)
+}
+
+// hoconScalar interprets an unquoted string as a boolean, null, or number when possible
+func hoconScalar(s string) eval.Value {
+	switch s {
+	case `true`:
+		return types.WrapBoolean(true)
+	case `false`:
+		return types.WrapBoolean(false)
+	case `null`:
+		return eval.UNDEF
+	}
+	if r, _ := utf8.DecodeRuneInString(s); r == '-' || r >= '0' && r <= '9' {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return types.WrapInteger(i)
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return types.WrapFloat(f)
+		}
+	}
+	return types.WrapString(s)
+}
@@ -0,0 +1,86 @@
+package impl_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/puppet-evaluator/eval"
+)
+
+func ExampleUnmarshalHoconFile() {
+	eval.Puppet.Do(func(c eval.Context) {
+		fmt.Println(impl.UnmarshalHoconFile(c, `test`, `test.conf`, []byte(`
+// JSON syntax
+"a": { "b": [1, 2.5, true, null], "c": "x\"y" }
+# HOCON syntax
+a.d = plain text, a.e = """raw "quoted" text"""
+a { b = [3] }
+`)))
+	})
+	// Output: {'a' => {'b' => [3], 'c' => 'x"y', 'd' => 'plain text', 'e' => 'raw "quoted" text'}}
+}
+
+func ExampleUnmarshalHoconFile_substitutions() {
+	eval.Puppet.Do(func(c eval.Context) {
+		fmt.Println(impl.UnmarshalHoconFile(c, `test`, `test.conf`, []byte(`
+base { user = admin, port = 80 }
+server = ${base} { port = 8080 }
+url = "http://"${server.user}"@host:"${server.port}
+list = [${base.port}] [443]
+optional = ${?not.there}
+`)))
+	})
+	// Output: {'base' => {'user' => 'admin', 'port' => 80}, 'server' => {'user' => 'admin', 'port' => 8080}, 'url' => 'http://admin@host:8080', 'list' => [80, 443]}
+}
+
+func ExampleUnmarshalHoconFile_selfReference() {
+	os.Setenv(`HIERA_HOCON_TEST_PATH`, `/usr/bin`)
+	defer os.Unsetenv(`HIERA_HOCON_TEST_PATH`)
+
+	eval.Puppet.Do(func(c eval.Context) {
+		fmt.Println(impl.UnmarshalHoconFile(c, `test`, `test.conf`, []byte(`
+path = /opt
+path = ${path}":/extra"
+path = ${path}":/more"
+HIERA_HOCON_TEST_PATH = ${HIERA_HOCON_TEST_PATH}":/bin"
+server { ports = [80] }
+server { ports = ${server.ports} [443] }
+optional = ${?optional}
+`)))
+	})
+	// Output: {'path' => '/opt:/extra:/more', 'HIERA_HOCON_TEST_PATH' => '/usr/bin:/bin', 'server' => {'ports' => [80, 443]}}
+}
+
+func ExampleUnmarshalHoconFile_unresolvedSelfReference() {
+	fmt.Println(eval.Puppet.TryWithParent(context.Background(), func(c eval.Context) error {
+		impl.UnmarshalHoconFile(c, `test`, `test.conf`, []byte("a = 1\nnot_in_env = ${not_in_env}x\n"))
+		return nil
+	}))
+	// Output: Provider 'test' failed to parse 'test.conf': line 2: could not resolve substitution ${not_in_env}
+}
+
+func ExampleUnmarshalHoconFile_unresolved() {
+	fmt.Println(eval.Puppet.TryWithParent(context.Background(), func(c eval.Context) error {
+		impl.UnmarshalHoconFile(c, `test`, `test.conf`, []byte("a = 1\nb = ${c}\n"))
+		return nil
+	}))
+	// Output: Provider 'test' failed to parse 'test.conf': line 2: could not resolve substitution ${c}
+}
+
+func ExampleUnmarshalHoconFile_cycle() {
+	fmt.Println(eval.Puppet.TryWithParent(context.Background(), func(c eval.Context) error {
+		impl.UnmarshalHoconFile(c, `test`, `test.conf`, []byte("a = ${b}\nb = ${a}\n"))
+		return nil
+	}))
+	// Output: Provider 'test' failed to parse 'test.conf': line 2: substitution ${a} refers to itself
+}
+
+func ExampleUnmarshalHoconFile_syntaxError() {
+	fmt.Println(eval.Puppet.TryWithParent(context.Background(), func(c eval.Context) error {
+		impl.UnmarshalHoconFile(c, `test`, `test.conf`, []byte("a {\n  b = 1\n"))
+		return nil
+	}))
+	// Output: Provider 'test' failed to parse 'test.conf': line 3: expected '}'
+}
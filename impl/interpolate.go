@@ -7,9 +7,86 @@ import (
 	"github.com/lyraproj/issue/issue"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
-var iplPattern = regexp.MustCompile(`%{[^}]*}`)
+// delimiters determines how interpolation expressions are recognized in strings. A prefix preceded
+// by its own first character is an escape that produces the prefix verbatim, i.e. "%%{" produces a
+// literal "%{" with the default delimiters.
+type delimiters struct {
+	prefix string
+	suffix string
+	escape string
+}
+
+var defaultDelimiters = newDelimiters(`%{`, `}`)
+
+func newDelimiters(prefix, suffix string) *delimiters {
+	first, _ := utf8.DecodeRuneInString(prefix)
+	return &delimiters{prefix, suffix, string(first) + prefix}
+}
+
+// replaceAll replaces each escape and each interpolation expression in the given string with the string
+// that the given function returns for it. An expression ends with the first suffix that isn't quoted, so
+// a quoted method argument may contain interpolation expressions of its own.
+func (d *delimiters) replaceAll(str string, replacer func(match string) string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(str, d.prefix)
+		if start < 0 {
+			break
+		}
+		if el := len(d.escape) - len(d.prefix); start >= el && strings.HasPrefix(str[start-el:], d.escape) {
+			b.WriteString(str[:start-el])
+			b.WriteString(replacer(d.escape))
+			str = str[start+len(d.prefix):]
+			continue
+		}
+		end := d.expressionEnd(str, start+len(d.prefix))
+		if end < 0 {
+			break
+		}
+		b.WriteString(str[:start])
+		b.WriteString(replacer(str[start:end]))
+		str = str[end:]
+	}
+	b.WriteString(str)
+	return b.String()
+}
+
+// expressionEnd returns the position after the suffix that ends the expression which starts at the
+// given position, or -1 if there is no such suffix. When no suffix is found outside of quotes, the
+// first suffix ends the expression.
+func (d *delimiters) expressionEnd(str string, start int) int {
+	var quote rune
+	for i, c := range str[start:] {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(str[start+i:], d.suffix):
+			return start + i + len(d.suffix)
+		}
+	}
+	if i := strings.Index(str[start:], d.suffix); i >= 0 {
+		return start + i + len(d.suffix)
+	}
+	return -1
+}
+
+// delimitersOf returns the delimiters that the given invocation was initialized with
+func delimitersOf(ic lookup.Invocation) *delimiters {
+	if v, ok := ic.Get(HieraDelimitersKey); ok {
+		if d, ok := v.(*delimiters); ok {
+			return d
+		}
+	}
+	return defaultDelimiters
+}
+
 var emptyInterpolations = map[string]bool {
 	``: true,
 	`::`: true,
@@ -19,6 +96,10 @@ var emptyInterpolations = map[string]bool {
 	"'::'": true,
 }
 
+// hieraEntryVariable is the name of the variable that interpolates as the name of the hierarchy entry
+// that is currently being read. It is empty when no hierarchy entry is being read.
+const hieraEntryVariable = `hiera_entry`
+
 // Interpolate resolves interpolations in the given value and returns the result
 func Interpolate(ic lookup.Invocation, value eval.Value, allowMethods bool) eval.Value {
 	result, _ := doInterpolate(ic, value, allowMethods)
@@ -67,71 +148,91 @@ const scopeMethod = 1
 const aliasMethod = 2
 const lookupMethod = 3
 const literalMethod = 4
+const upcaseMethod = 5
+const downcaseMethod = 6
+const capitalizeMethod = 7
+const joinMethod = 8
+
+// The separator used by the join method when none is given
+const defaultJoinSeparator = `,`
 
-var methodMatch = regexp.MustCompile(`^(\w+)\((?:["]([^"]+)["]|[']([^']+)['])\)$`)
+var methodMatch = regexp.MustCompile(`^(\w+)\((?:["]([^"]+)["]|[']([^']+)['])(\s*,\s*(?:["]([^"]*)["]|[']([^']*)[']))?\)$`)
 
-func getMethodAndData(expr string, allowMethods bool) (int, string) {
+// getMethodAndArgs returns the method of the given expression together with its arguments. An expression
+// that isn't a method call is a scope variable and its only argument is the expression itself. Only the
+// join method accepts a second argument.
+func getMethodAndArgs(expr string, allowMethods bool) (int, []string) {
 	if groups := methodMatch.FindStringSubmatch(expr); groups != nil {
 		if !allowMethods {
 			panic(eval.Error(HIERA_INTERPOLATION_METHOD_SYNTAX_NOT_ALLOWED, issue.NO_ARGS))
 		}
-		data := groups[2]
-		if data == `` {
-			data = groups[3]
+		args := []string{groups[2] + groups[3]}
+		if groups[4] != `` {
+			args = append(args, groups[5]+groups[6])
 		}
+		var method int
 		switch groups[1] {
 		case `alias`:
-			return aliasMethod, data
+			method = aliasMethod
 		case `hiera`, `lookup`:
-			return lookupMethod, data
+			method = lookupMethod
 		case `literal`:
-			return literalMethod, data
+			method = literalMethod
 		case `scope`:
-			return scopeMethod, data
+			method = scopeMethod
+		case `upcase`:
+			method = upcaseMethod
+		case `downcase`:
+			method = downcaseMethod
+		case `capitalize`:
+			method = capitalizeMethod
+		case `join`:
+			return joinMethod, args
 		default:
 			panic(eval.Error(HIERA_INTERPOLATION_UNKNOWN_INTERPOLATION_METHOD, issue.H{`name`: groups[1]}))
 		}
+		if len(args) > 1 {
+			panic(eval.Error(HIERA_INTERPOLATION_TOO_MANY_ARGUMENTS, issue.H{`name`: groups[1]}))
+		}
+		return method, args
 	}
-	return scopeMethod, expr
+	return scopeMethod, []string{expr}
 }
 
 func interpolateString(ic lookup.Invocation, str string, allowMethods bool) (result eval.Value, changed bool) {
 	changed = false
-	if strings.Index(str, `%{`) < 0 {
+	d := delimitersOf(ic)
+	if strings.Index(str, d.prefix) < 0 {
 		result = types.WrapString(str)
 		return
 	}
-	str = iplPattern.ReplaceAllStringFunc(str, func (match string) string {
-		expr := strings.TrimSpace(match[2:len(match)-1])
-		if emptyInterpolations[expr] {
-			return ``
-		}
-		var methodKey int
-		methodKey, expr = getMethodAndData(expr, allowMethods)
-		if methodKey == aliasMethod && match != str {
-			panic(eval.Error(HIERA_INTERPOLATION_ALIAS_NOT_ENTIRE_STRING, issue.NO_ARGS))
-		}
-
-		switch methodKey {
-		case literalMethod:
-			return expr
-		case scopeMethod:
-			key := NewKey(expr)
-			if val, ok := ic.Scope().Get(key.Root()); ok {
-				val, _ = doInterpolate(ic, val, allowMethods)
-				if val, ok = key.Dig(val); ok {
-					return val.String()
+	str = d.replaceAll(str, func(match string) string {
+		if match == d.escape {
+			return d.prefix
+		}
+		alternatives := splitAlternatives(strings.TrimSpace(match[len(d.prefix):len(match)-len(d.suffix)]))
+		entireString := match == str && len(alternatives) == 1
+		for i, expr := range alternatives {
+			if i > 0 {
+				if lit, ok := unquote(expr); ok {
+					return lit
 				}
 			}
-			return ``
-		default:
-			val := lookup.Lookup(ic, expr, eval.UNDEF, nil)
-			if methodKey == aliasMethod {
+			val, alias := interpolateExpression(ic, expr, allowMethods, entireString)
+			if alias {
 				result = val
 				return ``
 			}
-			return val.String()
+			if i == len(alternatives)-1 {
+				return val.String()
+			}
+			if val != eval.UNDEF {
+				if s := val.String(); s != `` {
+					return s
+				}
+			}
 		}
+		return ``
 	})
 	changed = true
 	if result == nil {
@@ -141,13 +242,147 @@ func interpolateString(ic lookup.Invocation, str string, allowMethods bool) (res
 
 }
 
+// interpolateExpression resolves one interpolation expression into a value. The second return value
+// is true when the expression is an alias, in which case it must constitute the entire string that
+// is interpolated and the value replaces that string.
+func interpolateExpression(ic lookup.Invocation, expr string, allowMethods, entireString bool) (eval.Value, bool) {
+	if emptyInterpolations[expr] {
+		return types.WrapString(``), false
+	}
+	methodKey, args := getMethodAndArgs(expr, allowMethods)
+	expr = args[0]
+	if methodKey == aliasMethod && !entireString {
+		panic(eval.Error(HIERA_INTERPOLATION_ALIAS_NOT_ENTIRE_STRING, issue.NO_ARGS))
+	}
+
+	switch methodKey {
+	case literalMethod:
+		return types.WrapString(expr), false
+	case scopeMethod:
+		if expr == hieraEntryVariable {
+			if iv, ok := ic.(*invocation); ok {
+				return types.WrapString(iv.hierarchyEntryName()), false
+			}
+			return types.WrapString(``), false
+		}
+		if val, ok := lookupInScope(ic, expr, allowMethods); ok {
+			return val, false
+		}
+		return types.WrapString(``), false
+	case joinMethod:
+		separator := defaultJoinSeparator
+		if len(args) > 1 {
+			separator = args[1]
+		}
+		return types.WrapString(joinValue(lookup.Lookup(ic, expr, eval.UNDEF, nil), separator)), false
+	case upcaseMethod, downcaseMethod, capitalizeMethod:
+		// The argument is a string that may contain interpolation expressions of its own
+		arg, _ := interpolateString(ic, expr, allowMethods)
+		return types.WrapString(changeCase(methodKey, arg.String())), false
+	default:
+		return lookup.Lookup(ic, expr, eval.UNDEF, nil), methodKey == aliasMethod
+	}
+}
+
+// joinValue returns the elements of the given array, interpolated as strings, joined with the given
+// separator. A value that isn't an array is interpolated like a single element.
+func joinValue(val eval.Value, separator string) string {
+	a, ok := val.(*types.ArrayValue)
+	if !ok {
+		return stringValue(val)
+	}
+	ss := make([]string, a.Len())
+	for i := range ss {
+		ss[i] = stringValue(a.At(i))
+	}
+	return strings.Join(ss, separator)
+}
+
+// changeCase returns the given string converted according to the given case changing method. The
+// capitalize method converts the first character to upper case and the rest to lower case.
+func changeCase(methodKey int, str string) string {
+	switch methodKey {
+	case upcaseMethod:
+		return strings.ToUpper(str)
+	case downcaseMethod:
+		return strings.ToLower(str)
+	default:
+		for i := range str {
+			if i > 0 {
+				return strings.ToUpper(str[:i]) + strings.ToLower(str[i:])
+			}
+		}
+		return strings.ToUpper(str)
+	}
+}
+
+// stringValue returns the string that the given element of a joined value is interpolated as. An
+// undef element is interpolated as an empty string.
+func stringValue(val eval.Value) string {
+	if val == eval.UNDEF {
+		return ``
+	}
+	return val.String()
+}
+
+// splitAlternatives splits the given expression on each '||' that isn't quoted. The alternatives
+// make up a chain where each alternative is used when those that precede it resolve to an empty
+// string.
+func splitAlternatives(expr string) []string {
+	var alternatives []string
+	var quote rune
+	start := 0
+	for i, c := range expr {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '|' && strings.HasPrefix(expr[i:], `||`) && i >= start:
+			alternatives = append(alternatives, strings.TrimSpace(expr[start:i]))
+			start = i + 2
+		}
+	}
+	return append(alternatives, strings.TrimSpace(expr[start:]))
+}
+
+// unquote returns the contents of the given string and true if the string is enclosed in single
+// or double quotes. Otherwise it returns the empty string and false.
+func unquote(expr string) (string, bool) {
+	if n := len(expr); n >= 2 && (expr[0] == '\'' || expr[0] == '"') && expr[n-1] == expr[0] {
+		return expr[1:n-1], true
+	}
+	return ``, false
+}
+
+// resolveInScope returns the value of the given, possibly dotted, scope variable or UNDEF if the variable
+// isn't found. Interpolation expressions in the value of the variable are resolved, so a variable can
+// refer to other variables. A variable that, directly or indirectly, refers to itself is an error.
 func resolveInScope(ic lookup.Invocation, expr string, allowMethods bool) eval.Value {
+	if val, ok := lookupInScope(ic, expr, allowMethods); ok {
+		return val
+	}
+	return eval.UNDEF
+}
+
+// lookupInScope is like resolveInScope but returns false when the variable isn't found, so that a
+// missing variable can be told apart from one that is undef. Only the value that the variable digs
+// into is interpolated, so a variable may refer to a sibling of its own within the same root value.
+func lookupInScope(ic lookup.Invocation, expr string, allowMethods bool) (eval.Value, bool) {
 	key := NewKey(expr)
 	if val, ok := ic.Scope().Get(key.Root()); ok {
-		val, _ = doInterpolate(ic, val, allowMethods)
 		if val, ok = key.Dig(val); ok {
-			return val
+			if iv, ok := ic.(*invocation); ok {
+				return iv.check(`scope('`+key.String()+`')`, func() (eval.Value, bool) {
+					v, _ := doInterpolate(ic, val, allowMethods)
+					return v, true
+				})
+			}
+			val, _ = doInterpolate(ic, val, allowMethods)
+			return val, true
 		}
 	}
-	return eval.UNDEF
+	return nil, false
 }
@@ -1,7 +1,10 @@
 package impl
 
 import (
+	"fmt"
+	"time"
 	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
 	"github.com/lyraproj/puppet-evaluator/utils"
 	"github.com/lyraproj/hiera/config"
 	"github.com/lyraproj/hiera/lookup"
@@ -13,10 +16,90 @@ const HieraTopProviderKey = `Hiera::TopProvider`
 const HieraGlobalOptionsKey = `Hiera::GlobalOptions`
 const HieraTopProviderCacheKey = `Hiera::TopProvider::Cache`
 const HieraConfigsKey = `Hiera::Config::`
+const HieraDelimitersKey = `Hiera::Delimiters`
+const HieraFileCacheKey = `Hiera::FileCache`
+const HieraTypeCacheKey = `Hiera::TypeCache`
 
+// RawOptionKey is the global option that, when set to true, makes lookups return the values
+// found by providers verbatim, i.e. without resolving interpolation expressions
+const RawOptionKey = `hiera::raw`
+
+// RequireAllPathsOptionKey is the global option that, when set to true, makes it an error when a
+// path location in the hierarchy resolves to a file that doesn't exist. Locations that stem from
+// globs and locations in the default_hierarchy are exempt.
+const RequireAllPathsOptionKey = `hiera::require_all_paths`
+
+// ConfigOptionKey is the global option that provides a programmatically created configuration,
+// typically created using a config.Builder. Its value must be a runtime value that wraps a
+// config.Config, or a config.ResolvedConfig which is then used as is, e.g. one created using
+// NewResolvedConfig.
+const ConfigOptionKey = `hiera::config`
+
+// FallbackConfigOptionKey is the global option that provides the path of a configuration file to use
+// when the configuration file of an invocation cannot be loaded, e.g. because it is malformed
+const FallbackConfigOptionKey = `hiera::fallback_config`
+
+// FileCacheOptionKey is the global option that provides the cache for parsed file data. Its value
+// must be a runtime value that wraps a *ConcurrentMap. Contexts that are initialized with the same
+// cache parse each file only once. A new cache is created when the option is not set.
+const FileCacheOptionKey = `hiera::file_cache`
+
+// ReplaceOptionsOptionKey is the global option that, when set to true, makes the options of a
+// hierarchy entry replace the options of the defaults instead of being merged over them
+const ReplaceOptionsOptionKey = `hiera::replace_options`
+
+// InterpolationPrefixOptionKey and InterpolationSuffixOptionKey are the global options that
+// determine what delimits an interpolation expression. The defaults are "%{" and "}".
+const InterpolationPrefixOptionKey = `hiera::interpolation_prefix`
+const InterpolationSuffixOptionKey = `hiera::interpolation_suffix`
+
+// MaxFileSizeOptionKey is the global option that limits the size, in bytes, of the data files that
+// providers will read. A file that is larger is an error. DefaultMaxFileSize is used when the option
+// is not set.
+const MaxFileSizeOptionKey = `hiera::max_file_size`
+
+const DefaultMaxFileSize = 100 * 1024 * 1024
+
+// MaxHierarchyLevelsOptionKey is the global option that limits the number of levels in the hierarchy
+// of a configuration. A configuration with a larger hierarchy is an error when it is resolved. The
+// number of levels is unlimited when the option is not set.
+const MaxHierarchyLevelsOptionKey = `hiera::max_hierarchy_levels`
+
+// KeyRewriteOptionKey is the global option that rewrites the keys that are passed to a lookup before
+// they are parsed. Its value is either a Hash[String,String] that maps old keys to new keys, or a
+// runtime value that wraps a func(string) string. Keys looked up from interpolation expressions are
+// not rewritten unless RewriteInterpolatedKeysOptionKey is set to true.
+const KeyRewriteOptionKey = `hiera::key_rewrite`
+const RewriteInterpolatedKeysOptionKey = `hiera::rewrite_interpolated_keys`
+
+// KeyTypesOptionKey is the global option that binds keys to types. Its value is a Hash[String,String]
+// that maps a key to a type expression, typically the name of a type alias or an Object type known to
+// the loader. The value found for a bound key must be an instance of the type. A hash that is found for
+// a key bound to an Object type is used to initialize an instance of that type. The same applies to a
+// default value that is used when the key isn't found, unless that default is undef.
+const KeyTypesOptionKey = `hiera::key_types`
+
+// NegativeCacheTTLOptionKey is the global option that enables caching of keys that are not found.
+// Its value is the number of seconds that a key that wasn't found by the top provider is remembered as
+// not found. Lookups of the key within that time don't query the provider again. Keys that are not
+// found are not cached when the option is not set.
+const NegativeCacheTTLOptionKey = `hiera::negative_cache_ttl`
+
+// notFoundEntry is stored in the shared cache for a key that was not found when negative caching
+// is enabled
+type notFoundEntry struct {
+	expires time.Time
+}
+
+// An invocation tracks the state of one lookup, i.e. the names and the data provider that it is
+// currently looking up. That state is not synchronized, so an invocation must not be used by concurrent
+// lookups. Each goroutine creates its own invocation using NewInvocation.
 type invocation struct {
 	eval.Context
 	nameStack []string
+
+	// The data provider that is currently performing a lookup, if any
+	provider lookup.DataProvider
 }
 
 // InitContext initializes the given context with the Hiera cache. The context initialized
@@ -25,7 +108,31 @@ func InitContext(c eval.Context, topProvider lookup.LookupKey, options map[strin
 	c.Set(HieraCacheKey, NewConcurrentMap(37))
 	c.Set(HieraTopProviderKey, topProvider)
 	c.Set(HieraTopProviderCacheKey, make(map[string]eval.Value, 23))
+	c.Set(HieraCacheStatsKey, &cacheCounters{})
 	c.Set(HieraGlobalOptionsKey, options)
+
+	fileCache := NewConcurrentMap(17)
+	if v, ok := options[FileCacheOptionKey]; ok {
+		if rv, ok := v.(*types.RuntimeValue); ok {
+			if fc, ok := rv.Interface().(*ConcurrentMap); ok {
+				fileCache = fc
+			}
+		}
+	}
+	c.Set(HieraFileCacheKey, fileCache)
+	c.Set(HieraTypeCacheKey, NewConcurrentMap(7))
+
+	prefix, suffix := defaultDelimiters.prefix, defaultDelimiters.suffix
+	if v, ok := options[InterpolationPrefixOptionKey]; ok {
+		prefix = v.String()
+	}
+	if v, ok := options[InterpolationSuffixOptionKey]; ok {
+		suffix = v.String()
+	}
+	if prefix == `` || suffix == `` {
+		panic(eval.Error(HIERA_EMPTY_INTERPOLATION_DELIMITER, issue.NO_ARGS))
+	}
+	c.Set(HieraDelimitersKey, newDelimiters(prefix, suffix))
 }
 
 func NewInvocation(c eval.Context) lookup.Invocation {
@@ -62,6 +169,29 @@ func (ic *invocation) globalOptions() map[string]eval.Value {
 	panic(eval.Error(HIERA_NOT_INITIALIZED, issue.NO_ARGS))
 }
 
+// globalOption returns the value of the given global option together with a boolean to indicate if
+// the option was found. Unlike the globalOptions method, this function doesn't require that the
+// context has been initialized with Hiera.
+func globalOption(ic lookup.Invocation, key string) (eval.Value, bool) {
+	if v, ok := ic.Get(HieraGlobalOptionsKey); ok {
+		if g, ok := v.(map[string]eval.Value); ok {
+			ov, found := g[key]
+			return ov, found
+		}
+	}
+	return nil, false
+}
+
+// booleanOption returns true when the given global option is set to true
+func booleanOption(ic lookup.Invocation, key string) bool {
+	if v, ok := globalOption(ic, key); ok {
+		if bv, ok := v.(*types.BooleanValue); ok {
+			return bv.Bool()
+		}
+	}
+	return false
+}
+
 func (ic *invocation) sharedCache() *ConcurrentMap {
 	if v, ok := ic.Get(HieraCacheKey); ok {
 		var sh *ConcurrentMap
@@ -72,9 +202,31 @@ func (ic *invocation) sharedCache() *ConcurrentMap {
 	panic(eval.Error(HIERA_NOT_INITIALIZED, issue.NO_ARGS))
 }
 
+// ResolvedConfig returns the resolved configuration for the given path, i.e. the configuration that the
+// given invocation uses for lookups. No file is read when the global option ConfigOptionKey provides the
+// configuration.
+func ResolvedConfig(ic lookup.Invocation, configPath string) config.ResolvedConfig {
+	if i, ok := ic.(*invocation); ok {
+		return i.Config(configPath)
+	}
+	return NewInvocation(ic).(*invocation).Config(configPath)
+}
+
+// Config returns the resolved configuration for the given path. No file is read when the global
+// option ConfigOptionKey provides the configuration.
 func (ic *invocation) Config(configPath string) config.ResolvedConfig {
 	val, _ := ic.sharedCache().EnsureSet(HieraConfigsKey + configPath, func() (interface{}, bool) {
-		return NewConfig(ic, configPath), true
+		if v, ok := globalOption(ic, ConfigOptionKey); ok {
+			if rv, ok := v.(*types.RuntimeValue); ok {
+				switch cfg := rv.Interface().(type) {
+				case config.Config:
+					return cfg.Resolve(ic), true
+				case config.ResolvedConfig:
+					return cfg, true
+				}
+			}
+		}
+		return NewConfig(ic, configPath).Resolve(ic), true
 	})
 	return val.(config.ResolvedConfig)
 }
@@ -82,46 +234,168 @@ func (ic *invocation) Config(configPath string) config.ResolvedConfig {
 func (ic *invocation) lookupViaCache(key lookup.Key, options map[string]eval.Value) (eval.Value, bool) {
 	rootKey := key.Root()
 
-	val, ok := ic.sharedCache().EnsureSet(rootKey, func() (interface{}, bool) {
-		globalOptions := ic.globalOptions()
-		if len(options) == 0 {
-			options = globalOptions
-		} else if len(globalOptions) > 0 {
-			no := make(map[string]eval.Value, len(options) + len(globalOptions))
-			for k, v := range globalOptions {
-				no[k] = v
-			}
-			for k, v := range options {
-				no[k] = v
-			}
-			options = no
+	ttl := negativeCacheTTL(ic)
+	for {
+		produced := false
+		val, ok := ic.sharedCache().EnsureSet(rootKey, func() (interface{}, bool) {
+			produced = true
+			return ic.lookupInTopProvider(rootKey, options, ttl)
+		})
+		if nf, ok := val.(*notFoundEntry); ok && !produced && !time.Now().Before(nf.expires) {
+			// The not found entry has expired so the top provider must be queried again
+			ic.sharedCache().Delete(rootKey)
+			continue
 		}
-		if v, ok := ic.topProvider()(newContext(ic, ic.topProviderCache()), rootKey, options); ok {
-			return Interpolate(ic, v, true), true
+		getCacheCounters(ic).countTopProvider(!produced)
+		if !ok {
+			return nil, false
+		}
+		if _, ok := val.(*notFoundEntry); ok {
+			return nil, false
 		}
-		return nil, false
-	})
-	if ok {
 		return key.Dig(val.(eval.Value))
 	}
+}
+
+// lookupInTopProvider performs the lookup of the given root key using the top provider. A notFoundEntry
+// is returned for a key that isn't found when ttl is positive.
+func (ic *invocation) lookupInTopProvider(rootKey string, options map[string]eval.Value, ttl time.Duration) (interface{}, bool) {
+	globalOptions := ic.globalOptions()
+	if len(options) == 0 {
+		options = globalOptions
+	} else if len(globalOptions) > 0 {
+		no := make(map[string]eval.Value, len(options) + len(globalOptions))
+		for k, v := range globalOptions {
+			no[k] = v
+		}
+		for k, v := range options {
+			no[k] = v
+		}
+		options = no
+	}
+	if v, ok := ic.topProvider()(newContext(ic, ic.topProviderCache()), rootKey, options); ok {
+		if booleanOption(ic, RawOptionKey) {
+			return v, true
+		}
+		return Interpolate(ic, v, true), true
+	}
+	if ttl > 0 {
+		return &notFoundEntry{time.Now().Add(ttl)}, true
+	}
 	return nil, false
 }
 
+// negativeCacheTTL returns the time that a key that is not found is cached
+func negativeCacheTTL(ic lookup.Invocation) time.Duration {
+	if v, ok := globalOption(ic, NegativeCacheTTLOptionKey); ok {
+		if nv, ok := v.(eval.NumericValue); ok {
+			return time.Duration(nv.Float() * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// rewriteKey returns the key that should be looked up in place of the given key
+func (ic *invocation) rewriteKey(name string) string {
+	rw, ok := globalOption(ic, KeyRewriteOptionKey)
+	if !ok || len(ic.nameStack) > 0 && !booleanOption(ic, RewriteInterpolatedKeysOptionKey) {
+		return name
+	}
+	newName := name
+	switch rw := rw.(type) {
+	case eval.OrderedMap:
+		if nv, ok := rw.Get4(name); ok {
+			newName = nv.String()
+		}
+	case *types.RuntimeValue:
+		if f, ok := rw.Interface().(func(string) string); ok {
+			newName = f(name)
+		}
+	}
+	if newName != name {
+		ic.Logger().Logf(eval.DEBUG, `lookup key '%s' rewritten to '%s'`, name, newName)
+	}
+	return newName
+}
+
+// bindType returns the given value found for the given key, asserted to be an instance of the type that
+// the key is bound to. The value is returned unaltered when the key isn't bound to a type.
+func (ic *invocation) bindType(name string, value eval.Value) eval.Value {
+	kt, ok := globalOption(ic, KeyTypesOptionKey)
+	if !ok {
+		return value
+	}
+	km, ok := kt.(eval.OrderedMap)
+	if !ok {
+		return value
+	}
+	tv, ok := km.Get4(name)
+	if !ok {
+		return value
+	}
+	t := ic.parseType(tv.String())
+	if eval.IsInstance(t, value) {
+		return value
+	}
+	if ot, ok := t.(eval.ObjectType); ok {
+		if _, ok := value.(eval.OrderedMap); ok {
+			return eval.New(ic, ot, value)
+		}
+	}
+	return eval.AssertInstance(func() string { return fmt.Sprintf(`value for key '%s'`, name) }, t, value)
+}
+
+// parseType returns the type that the given type expression parses into. Parsed types are cached in the
+// context so that each expression is parsed only once during the life-cycle of that context.
+func (ic *invocation) parseType(expr string) eval.Type {
+	v, ok := ic.Get(HieraTypeCacheKey)
+	if !ok {
+		return ic.ParseType2(expr)
+	}
+	t, _ := v.(*ConcurrentMap).EnsureSet(expr, func() (interface{}, bool) {
+		return ic.ParseType2(expr), true
+	})
+	return t.(eval.Type)
+}
+
 func (ic *invocation) Check(key lookup.Key, actor lookup.Producer) (eval.Value, bool) {
-	if utils.ContainsString(ic.nameStack, key.String()) {
+	return ic.check(key.String(), actor)
+}
+
+// check calls the given actor with the given name pushed onto the name stack. It panics if the name
+// is already on the stack.
+func (ic *invocation) check(name string, actor lookup.Producer) (eval.Value, bool) {
+	if utils.ContainsString(ic.nameStack, name) {
 		panic(eval.Error(HIERA_ENDLESS_RECURSION, issue.H{`name_stack`: ic.nameStack}))
 	}
-	ic.nameStack = append(ic.nameStack, key.String())
+	ic.nameStack = append(ic.nameStack, name)
 	defer func() {
 		ic.nameStack = ic.nameStack[:len(ic.nameStack)-1]
 	}()
 	return actor()
 }
 
+// WithDataProvider calls the given actor with the given data provider set as the current provider of the
+// receiver. The previous provider is restored when the actor returns. The receiver is modified without any
+// synchronization, which is one reason why an invocation must not be used by concurrent lookups.
 func (ic *invocation) WithDataProvider(dh lookup.DataProvider, actor lookup.Producer) (eval.Value, bool) {
+	saved := ic.provider
+	ic.provider = dh
+	defer func() {
+		ic.provider = saved
+	}()
 	return actor()
 }
 
+// hierarchyEntryName returns the name of the hierarchy entry of the data provider that is currently
+// performing a lookup, or an empty string when no such provider exists
+func (ic *invocation) hierarchyEntryName() string {
+	if hp, ok := ic.provider.(hierarchyProvider); ok && hp.hierarchyEntry() != nil {
+		return hp.hierarchyEntry().Name()
+	}
+	return ``
+}
+
 func (ic *invocation) WithLocation(loc lookup.Location, actor lookup.Producer) (eval.Value, bool) {
 	return actor()
 }
@@ -135,6 +409,10 @@ func (ic *invocation) ReportFound(key string, value eval.Value) {
 func (ic *invocation) ReportNotFound(key string) {
 }
 
+// ReportEvent makes the invocation a lookup.EventReporter
+func (ic *invocation) ReportEvent(kind string, details func() map[string]eval.Value) {
+}
+
 var notFoundSingleton = &lookup.NotFound{}
 
 func (ic *invocation) NotFound() {
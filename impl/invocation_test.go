@@ -0,0 +1,28 @@
+package impl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/hiera/provider"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+func BenchmarkLookup_keyTypes(b *testing.B) {
+	typedOptions := map[string]eval.Value{
+		`path`: options[`path`],
+		impl.KeyTypesOptionKey: types.WrapStringToInterfaceMap(eval.Puppet.RootContext(), map[string]interface{}{
+			`first`: `Pattern[/\Avalue of/]`,
+			`array`: `Array[Enum[one, two, three], 1, 10]`})}
+	lookup.DoWithParent(context.Background(), provider.Yaml, typedOptions, func(c eval.Context) {
+		ic := impl.NewInvocation(c)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			lookup.Lookup(ic, `first`, nil, nil)
+			lookup.Lookup(ic, `array`, nil, nil)
+		}
+	})
+}
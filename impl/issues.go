@@ -7,15 +7,22 @@ import (
 )
 
 const(
+	HIERA_DATA_FILE_PARSE_ERROR = `HIERA_DATA_FILE_PARSE_ERROR`
+	HIERA_DATA_FILE_TOO_LARGE = `HIERA_DATA_FILE_TOO_LARGE`
 	HIERA_DIG_MISMATCH = `HIERA_DIG_MISMATCH`
+	HIERA_EMPTY_INTERPOLATION_DELIMITER = `HIERA_EMPTY_INTERPOLATION_DELIMITER`
 	HIERA_EMPTY_KEY_SEGMENT = `HIERA_EMPTY_KEY_SEGMENT`
 	HIERA_ENDLESS_RECURSION = `HIERA_ENDLESS_RECURSION`
 	HIERA_FIRST_KEY_SEGMENT_INT = `HIERA_FIRST_KEY_SEGMENT_INT`
 	HIERA_HIERARCHY_NAME_MULTIPLY_DEFINED = `HIERA_HIERARCHY_NAME_MULTIPLY_DEFINED`
 	HIERA_INTERPOLATION_ALIAS_NOT_ENTIRE_STRING = `HIERA_INTERPOLATION_ALIAS_NOT_ENTIRE_STRING`
 	HIERA_INTERPOLATION_METHOD_SYNTAX_NOT_ALLOWED = `HIERA_INTERPOLATION_METHOD_SYNTAX_NOT_ALLOWED`
+	HIERA_INTERPOLATION_TOO_MANY_ARGUMENTS = `HIERA_INTERPOLATION_TOO_MANY_ARGUMENTS`
 	HIERA_INTERPOLATION_UNKNOWN_INTERPOLATION_METHOD = `HIERA_INTERPOLATION_UNKNOWN_INTERPOLATION_METHOD`
+	HIERA_INVALID_GLOB_PATTERN = `HIERA_INVALID_GLOB_PATTERN`
+	HIERA_LOCATION_KEY_NOT_AVAILABLE = `HIERA_LOCATION_KEY_NOT_AVAILABLE`
 	HIERA_MISSING_DATA_PROVIDER_FUNCTION = `HIERA_MISSING_DATA_PROVIDER_FUNCTION`
+	HIERA_MISSING_DATA_FILE = `HIERA_MISSING_DATA_FILE`
 	HIERA_MISSING_REQUIRED_OPTION = `HIERA_MISSING_REQUIRED_OPTION`
 	HIERA_MULTIPLE_DATA_PROVIDER_FUNCTIONS = `HIERA_MULTIPLE_DATA_PROVIDER_FUNCTIONS`
 	HIERA_MULTIPLE_LOCATION_SPECS = `HIERA_MULTIPLE_LOCATION_SPECS`
@@ -23,6 +30,8 @@ const(
 	HIERA_NOT_ANY_NAME_FOUND = `HIERA_NOT_ANY_NAME_FOUND`
 	HIERA_NOT_INITIALIZED = `HIERA_NOT_INITIALIZED`
 	HIERA_OPTION_RESERVED_BY_PUPPET = `HIERA_OPTION_RESERVED_BY_PUPPET`
+	HIERA_PROVIDER_ERROR = `HIERA_PROVIDER_ERROR`
+	HIERA_TOO_MANY_HIERARCHY_LEVELS = `HIERA_TOO_MANY_HIERARCHY_LEVELS`
 	HIERA_UNTERMINATED_QUOTE = `HIERA_UNTERMINATED_QUOTE`
 	HIERA_YAML_NOT_HASH = `HIERA_YAML_NOT_HASH`
 )
@@ -35,9 +44,15 @@ func joinNames(v interface{}) string {
 }
 
 func init() {
+	issue.Hard(HIERA_DATA_FILE_PARSE_ERROR, `Provider '%{provider}' failed to parse '%{path}': %{detail}`)
+
+	issue.Hard(HIERA_DATA_FILE_TOO_LARGE, `Data file '%{path}' is %{size} bytes which exceeds the maximum of %{max} bytes`)
+
 	issue.Hard(HIERA_DIG_MISMATCH,
 		`lookup() Got %{type} when a hash-like object was expected to access value using '%{segment}' from key '%{key}'`)
 
+	issue.Hard(HIERA_EMPTY_INTERPOLATION_DELIMITER, `Interpolation prefix and suffix cannot be empty`)
+
 	issue.Hard(HIERA_EMPTY_KEY_SEGMENT, `lookup() key '%{key}' contains an empty segment`)
 
 	issue.Hard2(HIERA_ENDLESS_RECURSION, `Recursive lookup detected in [%{name_stack}]`, issue.HF{`name_stack`: joinNames})
@@ -50,11 +65,19 @@ func init() {
 
 	issue.Hard(HIERA_INTERPOLATION_METHOD_SYNTAX_NOT_ALLOWED, `Interpolation using method syntax is not allowed in this context`)
 
+	issue.Hard(HIERA_INTERPOLATION_TOO_MANY_ARGUMENTS, `Interpolation method '%{name}' takes only one argument`)
+
 	issue.Hard(HIERA_INTERPOLATION_UNKNOWN_INTERPOLATION_METHOD, `Unknown interpolation method '%{name}'`)
 
+	issue.Hard(HIERA_INVALID_GLOB_PATTERN, `Invalid glob pattern '%{pattern}': %{detail}`)
+
+	issue.Hard(HIERA_LOCATION_KEY_NOT_AVAILABLE, `Location key '%{key}' is built-in, reserved, or already registered`)
+
 	issue.Hard2(HIERA_MISSING_DATA_PROVIDER_FUNCTION, `One of %{keys} must be defined in hierarchy '%{name}'`,
 		issue.HF{`keys`: joinNames})
 
+	issue.Hard(HIERA_MISSING_DATA_FILE, `Data file '%{path}' used in hierarchy '%{name}' does not exist`)
+
 	issue.Hard(HIERA_MISSING_REQUIRED_OPTION, `Missing required provider option '%{option}'`)
 
 	issue.Hard2(HIERA_MULTIPLE_DATA_PROVIDER_FUNCTIONS, `Only one of %{keys} can be defined in hierarchy '%{name}'`,
@@ -72,6 +95,10 @@ func init() {
 
 	issue.Hard(HIERA_OPTION_RESERVED_BY_PUPPET, `Option key '%{key}' used in hierarchy '%{name}' is reserved by Puppet`)
 
+	issue.Hard(HIERA_PROVIDER_ERROR, `Provider '%{provider}' failed to lookup '%{key}': %{detail}`)
+
+	issue.Hard(HIERA_TOO_MANY_HIERARCHY_LEVELS, `The hierarchy of '%{path}' has %{count} levels which exceeds the maximum of %{max} levels`)
+
 	issue.Hard(HIERA_UNTERMINATED_QUOTE, `Unterminated quote in key '%{key}'`)
 
 	issue.Hard(HIERA_YAML_NOT_HASH, `File '%{path}' does not contain a YAML hash`)
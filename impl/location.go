@@ -7,8 +7,11 @@ import (
 	"path/filepath"
 "fmt"
 "os"
+"net/url"
 "github.com/bmatcuk/doublestar"
 "github.com/lyraproj/puppet-evaluator/impl"
+	"github.com/lyraproj/issue/issue"
+	"sort"
 )
 
 type path struct {
@@ -29,11 +32,19 @@ func (p* path) String() string {
 	return fmt.Sprintf("path{ original:%s, resolved:%s, exist:%v}", p.original, p.resolved, p.exist)
 }
 
+// Resolve interpolates the path and joins it with the data directory. The resolved location exists when
+// it names a file, possibly through symlinks. A path that names a directory doesn't exist since a
+// directory cannot be read as data.
 func (p* path) Resolve(ic lookup.Invocation, dataDir string) []lookup.Location {
 	r, _ := interpolateString(ic, p.original, false)
 	rp := filepath.Join(dataDir, r.String())
-	_, err := os.Stat(rp)
-	return []lookup.Location{&path{p.original, rp, err == nil}}
+	return []lookup.Location{&path{p.original, rp, isFile(rp)}}
+}
+
+// isFile returns true if the given path exists and, after following symlinks, is not a directory
+func isFile(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && !fi.IsDir()
 }
 
 type glob struct {
@@ -52,15 +63,45 @@ func (g* glob) String() string {
 	return fmt.Sprintf("glob{pattern:%s}", g.pattern)
 }
 
+// Resolve interpolates the pattern, joins it with the data directory, and returns one existing path
+// location for each matched file in sorted order. Symlinks are followed. Matched directories are excluded
+// and a file that is matched using several paths is returned once, using the shortest path.
 func (g* glob) Resolve(ic lookup.Invocation, dataDir string) []lookup.Location {
 	r, _ := interpolateString(ic, g.pattern, false)
 	rp := filepath.Join(dataDir, r.String())
+	// doublestar supports "**" as a recursive wildcard. The order of its matches depends on the order that
+	// directories are read, so they are sorted to keep the resolution deterministic.
 	matches, err := doublestar.Glob(rp)
 	if err != nil {
-
+		panic(eval.Error(HIERA_INVALID_GLOB_PATTERN, issue.H{`pattern`: rp, `detail`: err.Error()}))
+	}
+	// Symlinks are followed, so the same file can be matched using several paths, e.g. when a symlink
+	// points to one of its parent directories. Only the shortest of those paths is used. Directories are
+	// matched by some patterns but they are not data files.
+	sort.Slice(matches, func(i, j int) bool {
+		if len(matches[i]) != len(matches[j]) {
+			return len(matches[i]) < len(matches[j])
+		}
+		return matches[i] < matches[j]
+	})
+	files := make([]string, 0, len(matches))
+	seen := make(map[string]bool, len(matches))
+	for _, m := range matches {
+		if !isFile(m) {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(m); err == nil {
+			if seen[real] {
+				continue
+			}
+			seen[real] = true
+		}
+		files = append(files, m)
 	}
-	locs := make([]lookup.Location, len(matches))
-	for i, m := range matches {
+	sort.Strings(files)
+
+	locs := make([]lookup.Location, len(files))
+	for i, m := range files {
 		locs[i] = &path{g.pattern, m, true}
 	}
 	return locs
@@ -85,7 +126,14 @@ func (u* uri) String() string {
 
 func (u* uri) Resolve(ic lookup.Invocation, dataDir string) []lookup.Location {
 	r, _ := interpolateString(ic, u.original, false)
-	return []lookup.Location{&uri{u.original, r.String()}}
+	rs := r.String()
+	if pu, err := url.Parse(rs); err == nil && pu.Scheme == `file` {
+		// A file URI is a path to a local file that can be read like any other path. Like a path, it
+		// doesn't exist when it is a directory.
+		rp := filepath.FromSlash(pu.Path)
+		return []lookup.Location{&path{u.original, rp, isFile(rp)}}
+	}
+	return []lookup.Location{&uri{u.original, rs}}
 }
 
 type mappedPaths struct {
@@ -95,7 +143,8 @@ type mappedPaths struct {
 	// Variable name to use when resolving template
 	key string
 
-	// Template containing interpolation of the key
+	// Template containing interpolation of the key. When the elements of the array are hashes, the
+	// template can interpolate any number of their entries using dotted keys, e.g. "%{site.region}"
 	template string
 }
 
@@ -0,0 +1,145 @@
+package impl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/puppet-evaluator/eval"
+)
+
+func TestUri_Resolve_file(t *testing.T) {
+	abs, err := filepath.Abs(`testdata/sample_data.yaml`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withFacts(t, map[string]interface{}{`file`: `sample_data`}, func(ic *invocation) {
+		u := &uri{original: `file://` + filepath.ToSlash(filepath.Dir(abs)) + `/%{facts.file}.yaml`}
+		locs := u.Resolve(ic, `data`)
+		if len(locs) != 1 || locs[0].Kind() != lookup.LC_PATH {
+			t.Fatalf(`expected a single path location, got %v`, locs)
+		}
+		if p := locs[0].(*path); p.resolved != abs || !p.Exist() {
+			t.Errorf(`unexpected location %s`, p)
+		}
+
+		// A directory is not a data file, so it does not count as an existing location
+		d := &uri{original: `file://` + filepath.ToSlash(filepath.Dir(abs))}
+		locs = d.Resolve(ic, `data`)
+		if len(locs) != 1 || locs[0].Kind() != lookup.LC_PATH {
+			t.Fatalf(`expected a single path location, got %v`, locs)
+		}
+		if p := locs[0].(*path); p.resolved != filepath.Dir(abs) || p.Exist() {
+			t.Errorf(`expected location %s to not exist`, p)
+		}
+	})
+}
+
+func TestUri_Resolve_nonFile(t *testing.T) {
+	withFacts(t, map[string]interface{}{}, func(ic *invocation) {
+		locs := (&uri{original: `https://example.com/common.yaml`}).Resolve(ic, `data`)
+		if len(locs) != 1 || locs[0].Kind() != lookup.LC_URI {
+			t.Fatalf(`expected a single uri location, got %v`, locs)
+		}
+	})
+}
+
+func TestGlob_Resolve_recursive(t *testing.T) {
+	withFacts(t, map[string]interface{}{}, func(ic *invocation) {
+		locs := (&glob{pattern: `**/*.yaml`}).Resolve(ic, `testdata/nested/data`)
+		expected := []string{
+			`testdata/nested/data/a/b/deep.yaml`,
+			`testdata/nested/data/a/shallow.yaml`,
+			`testdata/nested/data/c/other.yaml`,
+			`testdata/nested/data/top.yaml`}
+		if len(locs) != len(expected) {
+			t.Fatalf(`expected %d locations, got %v`, len(expected), locs)
+		}
+		for i, loc := range locs {
+			if p := loc.(*path); p.resolved != filepath.FromSlash(expected[i]) || !p.Exist() {
+				t.Errorf(`unexpected location %s at %d`, p, i)
+			}
+		}
+	})
+}
+
+func TestGlob_Resolve_symlinkedDataDir(t *testing.T) {
+	tmp, err := ioutil.TempDir(``, `hiera`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// release/data contains the files. The data directory is a symlink to it and release/data/sub/loop
+	// points to release/data so that every file can be reached using an infinite number of paths.
+	release := filepath.Join(tmp, `release`, `data`)
+	if err = os.MkdirAll(filepath.Join(release, `sub`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{`common.yaml`, filepath.Join(`sub`, `node.yaml`)} {
+		if err = ioutil.WriteFile(filepath.Join(release, f), []byte("a: b\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dataDir := filepath.Join(tmp, `data`)
+	if err = os.Symlink(release, dataDir); err != nil {
+		t.Skipf(`symlinks are not supported: %s`, err)
+	}
+	if err = os.Symlink(release, filepath.Join(release, `sub`, `loop`)); err != nil {
+		t.Skipf(`symlinks are not supported: %s`, err)
+	}
+
+	withFacts(t, map[string]interface{}{}, func(ic *invocation) {
+		locs := (&glob{pattern: `**/*.yaml`}).Resolve(ic, dataDir)
+		expected := []string{filepath.Join(dataDir, `common.yaml`), filepath.Join(dataDir, `sub`, `node.yaml`)}
+		if len(locs) != len(expected) {
+			t.Fatalf(`expected %d locations, got %v`, len(expected), locs)
+		}
+		for i, loc := range locs {
+			if p := loc.(*path); p.resolved != expected[i] || !p.Exist() {
+				t.Errorf(`unexpected location %s at %d`, p, i)
+			}
+		}
+
+		if locs = (&glob{pattern: `*`}).Resolve(ic, dataDir); len(locs) != 1 {
+			t.Errorf(`expected directories to be excluded, got %v`, locs)
+		}
+
+		if p := (&path{original: `sub/node.yaml`}).Resolve(ic, dataDir)[0].(*path); !p.Exist() {
+			t.Errorf(`expected %s to exist`, p)
+		}
+		if p := (&path{original: `sub`}).Resolve(ic, dataDir)[0].(*path); p.Exist() {
+			t.Errorf(`expected directory %s to not exist as a data file`, p)
+		}
+	})
+}
+
+func TestMappedPaths_Resolve_arrayOfMaps(t *testing.T) {
+	facts := map[string]interface{}{`sites`: []interface{}{
+		map[string]interface{}{`region`: `eu`, `name`: `paris`},
+		map[string]interface{}{`region`: `us`, `name`: `boston`}}}
+	withFacts(t, facts, func(ic *invocation) {
+		locs := (&mappedPaths{`facts.sites`, `site`, `%{site.region}/%{site.name}.yaml`}).Resolve(ic, `data`)
+		expected := []string{`data/eu/paris.yaml`, `data/us/boston.yaml`}
+		if len(locs) != len(expected) {
+			t.Fatalf(`expected %d locations, got %v`, len(expected), locs)
+		}
+		for i, loc := range locs {
+			if p := loc.(*path); p.resolved != filepath.FromSlash(expected[i]) {
+				t.Errorf(`unexpected location %s at %d`, p, i)
+			}
+		}
+	})
+}
+
+func TestMappedPaths_Resolve_keyNotLeaked(t *testing.T) {
+	facts := map[string]interface{}{`sites`: []interface{}{map[string]interface{}{`name`: `paris`}}}
+	withFacts(t, facts, func(ic *invocation) {
+		(&mappedPaths{`facts.sites`, `site`, `%{site.name}.yaml`}).Resolve(ic, `data`)
+		if v := resolveInScope(ic, `site`, false); v != eval.UNDEF {
+			t.Errorf(`expected 'site' to be undef after resolve, got %s`, v)
+		}
+	})
+}
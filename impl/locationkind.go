@@ -0,0 +1,114 @@
+package impl
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+	"github.com/lyraproj/puppet-evaluator/utils"
+)
+
+// A LocationFactory creates the locations of a hierarchy entry from the value that the entry declares
+// for a custom location key. The value can be any Data. The created locations are resolved like the
+// built-in ones, i.e. by calling their Resolve method with the data directory of the entry.
+type LocationFactory func(value eval.Value) []lookup.Location
+
+var locationKindsLock sync.RWMutex
+
+var locationKinds = map[string]LocationFactory{}
+
+// Keys of a hierarchy entry that cannot be used as a custom location key
+var entryKeys = []string{`name`, `options`, `datadir`, `priority`}
+
+// RegisterLocationKind makes the given key a valid location key of a hierarchy entry, e.g. "s3". The
+// factory is called with the value declared for the key when an entry that uses it is created. Like
+// the built-in location keys, the key cannot be combined with other location keys in the same entry.
+//
+// A custom location key cannot be one of the built-in location keys, a key that has another meaning
+// in a hierarchy entry, or a key that has already been registered.
+func RegisterLocationKind(key string, factory LocationFactory) {
+	locationKindsLock.Lock()
+	defer locationKindsLock.Unlock()
+
+	if _, ok := locationKinds[key]; ok ||
+		utils.ContainsString(config.LOCATION_KEYS, key) ||
+		utils.ContainsString(config.FUNCTION_KEYS, key) ||
+		utils.ContainsString(entryKeys, key) {
+		panic(eval.Error(HIERA_LOCATION_KEY_NOT_AVAILABLE, issue.H{`key`: key}))
+	}
+	locationKinds[key] = factory
+}
+
+// unregisterLocationKind removes a key that was registered using RegisterLocationKind. It exists so
+// that tests can leave the process global registry the way they found it.
+func unregisterLocationKind(key string) {
+	locationKindsLock.Lock()
+	delete(locationKinds, key)
+	locationKindsLock.Unlock()
+}
+
+// locationFactory returns the factory that has been registered for the given key
+func locationFactory(key string) (LocationFactory, bool) {
+	locationKindsLock.RLock()
+	f, ok := locationKinds[key]
+	locationKindsLock.RUnlock()
+	return f, ok
+}
+
+// locationKeys returns the built-in location keys followed by the registered ones in sorted order
+func locationKeys() []string {
+	locationKindsLock.RLock()
+	custom := make([]string, 0, len(locationKinds))
+	for k := range locationKinds {
+		custom = append(custom, k)
+	}
+	locationKindsLock.RUnlock()
+	sort.Strings(custom)
+	return append(append(make([]string, 0, len(config.LOCATION_KEYS)+len(custom)), config.LOCATION_KEYS...), custom...)
+}
+
+// withoutCustomLocations returns a copy of the given configuration hash where the custom location keys
+// have been removed from all hierarchy entries. It is used when the hash is validated against the
+// Hiera::Config type since that type only knows about the built-in location keys.
+func withoutCustomLocations(cfg eval.Value) eval.Value {
+	hash, ok := cfg.(*types.HashValue)
+	if !ok {
+		return cfg
+	}
+	locationKindsLock.RLock()
+	n := len(locationKinds)
+	locationKindsLock.RUnlock()
+	if n == 0 {
+		return cfg
+	}
+
+	return hash.Merge(types.WrapHash(hierarchiesWithoutCustomLocations(hash)))
+}
+
+func hierarchiesWithoutCustomLocations(hash *types.HashValue) []*types.HashEntry {
+	var es []*types.HashEntry
+	for _, hk := range []string{`hierarchy`, `default_hierarchy`} {
+		hv, ok := hash.Get4(hk)
+		if !ok {
+			continue
+		}
+		av, ok := hv.(*types.ArrayValue)
+		if !ok {
+			continue
+		}
+		es = append(es, types.WrapHashEntry2(hk, av.Map(func(ev eval.Value) eval.Value {
+			if eh, ok := ev.(*types.HashValue); ok {
+				return eh.RejectPairs(func(k, _ eval.Value) bool {
+					_, custom := locationFactory(k.String())
+					return custom
+				})
+			}
+			return ev
+		})))
+	}
+	return es
+}
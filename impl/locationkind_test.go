@@ -0,0 +1,87 @@
+package impl_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/hiera/provider"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	evalimpl "github.com/lyraproj/puppet-evaluator/impl"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+// s3Object is a custom location that appoints an object in an S3 bucket
+type s3Object struct {
+	key      string
+	resolved string
+}
+
+func (s *s3Object) Kind() lookup.LocationKind {
+	return lookup.LocationKind(`s3`)
+}
+
+func (s *s3Object) Exist() bool {
+	return s.resolved != ``
+}
+
+func (s *s3Object) String() string {
+	return fmt.Sprintf(`s3{key:%s, resolved:%s}`, s.key, s.resolved)
+}
+
+func (s *s3Object) Resolve(ic lookup.Invocation, dataDir string) []lookup.Location {
+	r := &s3Object{s.key, dataDir + `/` + impl.Interpolate(ic, types.WrapString(s.key), false).String()}
+	fmt.Println(`resolved`, r)
+	return []lookup.Location{r}
+}
+
+func ExampleRegisterLocationKind() {
+	impl.RegisterLocationKind(`s3`, func(value eval.Value) []lookup.Location {
+		return []lookup.Location{&s3Object{key: value.String()}}
+	})
+	defer impl.UnregisterLocationKind(`s3`)
+
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, nil, func(c eval.Context) error {
+		facts := types.WrapStringToInterfaceMap(c, map[string]interface{}{`facts`: map[string]interface{}{`env`: `prod`}})
+		c.DoWithScope(evalimpl.NewScope2(facts, false), func() {
+			ic := impl.NewInvocation(c)
+			cfg := impl.NewConfig(ic, `testdata/customlocation/hiera.yaml`)
+			for _, he := range cfg.Hierarchy() {
+				fmt.Println(he.Name())
+			}
+			cfg.Resolve(ic)
+		})
+		return nil
+	}))
+
+	err := lookup.TryWithParent(context.Background(), provider.Yaml, nil, func(c eval.Context) error {
+		impl.NewConfig(impl.NewInvocation(c), `testdata/customlocation/mixed.yaml`)
+		return nil
+	})
+	fmt.Println(strings.TrimSpace(err.Error()))
+
+	err = lookup.TryWithParent(context.Background(), provider.Yaml, nil, func(c eval.Context) error {
+		impl.NewConfigBuilder(`/etc/hiera`).Hierarchy(`Mixed`, func(hb config.HierarchyEntryBuilder) {
+			hb.Path(`common.yaml`)
+			hb.Location(&s3Object{key: `common.yaml`})
+		})
+		return nil
+	})
+	fmt.Println(strings.TrimSpace(err.Error()))
+
+	fmt.Println(lookup.TryWithParent(context.Background(), provider.Yaml, nil, func(c eval.Context) error {
+		impl.RegisterLocationKind(`glob`, func(value eval.Value) []lookup.Location { return nil })
+		return nil
+	}))
+	// Output:
+	// Per environment
+	// Common
+	// resolved s3{key:%{facts.env}/common.yaml, resolved:data/prod/common.yaml}
+	// <nil>
+	// Only one of path, paths, glob, globs, uri, uris, mapped_paths, s3 can be defined in hierarchy 'Mixed'
+	// Only one of path, paths, glob, globs, uri, uris, mapped_paths, s3 can be defined in hierarchy 'Mixed'
+	// Location key 'glob' is built-in, reserved, or already registered
+}
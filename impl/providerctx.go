@@ -4,7 +4,10 @@ import (
 	"github.com/lyraproj/puppet-evaluator/eval"
 	"github.com/lyraproj/puppet-evaluator/types"
 	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
 	"io"
+	"os"
+	"path/filepath"
 )
 
 var ContextType eval.ObjectType
@@ -67,6 +70,15 @@ func (c *providerCtx) Call(ctx eval.Context, method eval.ObjFunc, args []eval.Va
 		}
 	case `cached_entries`:
 		c.CachedEntries(func(k, v eval.Value) { block.Call(ctx, nil, k, v)})
+	case `cached_file_data`:
+		if v, ok := c.CachedFileData(args[0].String(), func(content []byte) eval.Value {
+			if block == nil {
+				return types.WrapString(string(content))
+			}
+			return block.Call(ctx, nil, types.WrapString(string(content)))
+		}); ok {
+			result = v
+		}
 	case `explain`:
 		c.Explain(func() string { return block.Call(ctx, nil).String() })
 	case `not_found`:
@@ -140,6 +152,69 @@ func (c *providerCtx) CachedEntries(consumer eval.BiConsumer) {
 	}
 }
 
+// CachedFileData makes the context a lookup.FileDataCache
+func (c *providerCtx) CachedFileData(path string, parser func(content []byte) eval.Value) (eval.Value, bool) {
+	return c.cachedFileData(path, ``, parser)
+}
+
+// CachedFileVariant is like lookup.CachedFileData but the value is cached using the absolute path
+// of the file together with the given variant. It is used when the same file can be parsed in different
+// ways, e.g. with and without strict checks, so that one way of parsing the file never yields the value
+// that was produced by another. The variant is ignored when the context isn't created by this package.
+func CachedFileVariant(c lookup.ProviderContext, path, variant string, parser func(content []byte) eval.Value) (eval.Value, bool) {
+	if pc, ok := c.(*providerCtx); ok {
+		return pc.cachedFileData(path, variant, parser)
+	}
+	return lookup.CachedFileData(c, path, parser)
+}
+
+func (c *providerCtx) cachedFileData(path, variant string, parser func(content []byte) eval.Value) (eval.Value, bool) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	cacheKey := path
+	if variant != `` {
+		cacheKey += "\x00" + variant
+	}
+	produced := false
+	v, ok := c.fileCache().EnsureSet(cacheKey, func() (interface{}, bool) {
+		produced = true
+		assertFileSize(c.invocation, path)
+		if bin, ok := types.BinaryFromFile2(c.invocation, path); ok {
+			return parser(bin.Bytes()), true
+		}
+		return nil, false
+	})
+	getCacheCounters(c.invocation).countFile(!produced)
+	if ok {
+		return v.(eval.Value), true
+	}
+	return nil, false
+}
+
+// assertFileSize panics if the file at the given path is larger than the maximum file size
+func assertFileSize(ic lookup.Invocation, path string) {
+	max := int64(DefaultMaxFileSize)
+	if v, ok := globalOption(ic, MaxFileSizeOptionKey); ok {
+		if iv, ok := v.(*types.IntegerValue); ok {
+			max = iv.Int()
+		}
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Size() > max {
+		panic(eval.Error(HIERA_DATA_FILE_TOO_LARGE, issue.H{`path`: path, `size`: fi.Size(), `max`: max}))
+	}
+}
+
+func (c *providerCtx) fileCache() *ConcurrentMap {
+	if v, ok := c.invocation.Get(HieraFileCacheKey); ok {
+		var fc *ConcurrentMap
+		if fc, ok = v.(*ConcurrentMap); ok {
+			return fc
+		}
+	}
+	panic(eval.Error(HIERA_NOT_INITIALIZED, issue.NO_ARGS))
+}
+
 func (c *providerCtx) Invocation() lookup.Invocation {
 	return c.invocation
 }
@@ -0,0 +1,99 @@
+package impl
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+var evalValueType = reflect.TypeOf((*eval.Value)(nil)).Elem()
+
+// ToMap converts the given value into a hash that can be used as a scope, e.g. by passing it to
+// impl.NewScope2 of the evaluator. The value can be a hash, a map with string keys, or a struct or a
+// pointer to a struct. An empty hash is returned for any other value.
+//
+// The exported fields of a struct become entries of the hash. The name of an entry can be given using
+// a `hiera:"name"` tag and defaults to the name of the field. A field tagged with `hiera:"-"` is
+// ignored. Nested structs and maps become nested hashes and slices become arrays. All other values are
+// wrapped using eval.Wrap.
+func ToMap(c eval.Context, v interface{}) *types.HashValue {
+	if hv, ok := reflectValue(c, reflect.ValueOf(v)).(*types.HashValue); ok {
+		return hv
+	}
+	return types.WrapHash([]*types.HashEntry{})
+}
+
+func reflectValue(c eval.Context, rv reflect.Value) eval.Value {
+	if !rv.IsValid() {
+		return eval.UNDEF
+	}
+	if rv.Type().Implements(evalValueType) {
+		if rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return eval.UNDEF
+			}
+		}
+		return rv.Interface().(eval.Value)
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return eval.UNDEF
+		}
+		return reflectValue(c, rv.Elem())
+	case reflect.Struct:
+		return reflectStruct(c, rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			return reflectMap(c, rv)
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() != reflect.Uint8 {
+			vs := make([]eval.Value, rv.Len())
+			for i := range vs {
+				vs[i] = reflectValue(c, rv.Index(i))
+			}
+			return types.WrapValues(vs)
+		}
+	}
+	return eval.Wrap(c, rv.Interface())
+}
+
+func reflectStruct(c eval.Context, rv reflect.Value) eval.Value {
+	rt := rv.Type()
+	es := make([]*types.HashEntry, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != `` {
+			// Unexported field
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup(`hiera`); ok {
+			if ci := strings.IndexByte(tag, ','); ci >= 0 {
+				tag = tag[:ci]
+			}
+			if tag == `-` {
+				continue
+			}
+			if tag != `` {
+				name = tag
+			}
+		}
+		es = append(es, types.WrapHashEntry2(name, reflectValue(c, rv.Field(i))))
+	}
+	return types.WrapHash(es)
+}
+
+func reflectMap(c eval.Context, rv reflect.Value) eval.Value {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	es := make([]*types.HashEntry, len(keys))
+	for i, k := range keys {
+		es[i] = types.WrapHashEntry2(k.String(), reflectValue(c, rv.MapIndex(k)))
+	}
+	return types.WrapHash(es)
+}
@@ -8,17 +8,42 @@ import (
 )
 
 func UnmarshalYaml(c eval.Context, data []byte) eval.Value {
+	v, err := unmarshalYaml(c, data)
+	if err != nil {
+		panic(eval.Error(eval.EVAL_PARSE_ERROR, issue.H{`language`: `YAML`, `detail`: err.Error()}))
+	}
+	return v
+}
+
+// UnmarshalYamlFile is like UnmarshalYaml but a parse error will name the given provider and the path
+// of the file that the data was read from. When strict is true, a mapping that contains the same key
+// more than once is a parse error. Otherwise the last occurrence of the key wins.
+func UnmarshalYamlFile(c eval.Context, provider, path string, data []byte, strict bool) eval.Value {
+	if strict {
+		var itm interface{}
+		if err := yaml.UnmarshalStrict(data, &itm); err != nil {
+			panic(eval.Error(HIERA_DATA_FILE_PARSE_ERROR, issue.H{`provider`: provider, `path`: path, `detail`: err.Error()}))
+		}
+	}
+	v, err := unmarshalYaml(c, data)
+	if err != nil {
+		panic(eval.Error(HIERA_DATA_FILE_PARSE_ERROR, issue.H{`provider`: provider, `path`: path, `detail`: err.Error()}))
+	}
+	return v
+}
+
+func unmarshalYaml(c eval.Context, data []byte) (eval.Value, error) {
 	ms := make(yaml.MapSlice, 0)
 	err := yaml.Unmarshal([]byte(data), &ms)
 	if err != nil {
 		var itm interface{}
 		err2 := yaml.Unmarshal([]byte(data), &itm)
 		if err2 != nil {
-			panic(eval.Error(eval.EVAL_PARSE_ERROR, issue.H{`language`: `YAML`, `detail`: err.Error()}))
+			return nil, err
 		}
-		return wrapValue(c, itm)
+		return wrapValue(c, itm), nil
 	}
-	return wrapSlice(c, ms)
+	return wrapSlice(c, ms), nil
 }
 
 func wrapSlice(c eval.Context, ms yaml.MapSlice) eval.Value {
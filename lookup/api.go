@@ -45,6 +45,28 @@ type ProviderContext interface {
 	Invocation() Invocation
 }
 
+// A FileDataCache is a ProviderContext that caches the data that providers parse from files. Not every
+// ProviderContext is a FileDataCache, so providers obtain file data using the CachedFileData function.
+type FileDataCache interface {
+	// CachedFileData returns the value that the given parser produces from the contents of the file
+	// at the given path together with a boolean to indicate if the file was found. The value is cached
+	// using the absolute path of the file so that the file is parsed only once.
+	CachedFileData(path string, parser func(content []byte) eval.Value) (eval.Value, bool)
+}
+
+// CachedFileData returns the value that the given parser produces from the contents of the file at the
+// given path together with a boolean to indicate if the file was found. The value is cached when the given
+// context is a FileDataCache. Otherwise, the file is read and parsed on each call.
+func CachedFileData(c ProviderContext, path string, parser func(content []byte) eval.Value) (eval.Value, bool) {
+	if fc, ok := c.(FileDataCache); ok {
+		return fc.CachedFileData(path, parser)
+	}
+	if bin, ok := types.BinaryFromFile2(c.Invocation(), path); ok {
+		return parser(bin.Bytes()), true
+	}
+	return nil, false
+}
+
 type Producer func() (eval.Value, bool)
 
 // An Invocation keeps track of one specific lookup invocation implements a guard against
@@ -72,6 +94,23 @@ type Invocation interface {
 	ReportNotFound(key string)
 }
 
+// An EventReporter is an Invocation that can add structured events to the lookup explainer. It enables
+// custom DataProvider implementations to explain what they do, e.g. which remote path they query. Not
+// every Invocation is an EventReporter, so events are reported using the ReportEvent function.
+type EventReporter interface {
+	// ReportEvent adds a structured event of the given kind to the lookup explainer. The details
+	// function will only get called when the explanation support is enabled.
+	ReportEvent(kind string, details func() map[string]eval.Value)
+}
+
+// ReportEvent adds a structured event of the given kind to the lookup explainer of the given invocation.
+// Nothing is reported when the invocation isn't an EventReporter.
+func ReportEvent(ic Invocation, kind string, details func() map[string]eval.Value) {
+	if er, ok := ic.(EventReporter); ok {
+		er.ReportEvent(kind, details)
+	}
+}
+
 
 // A Key is a parsed version of the possibly dot-separated key to lookup. The
 // parts of a key will be strings or integers
@@ -100,6 +139,31 @@ func Lookup(ic Invocation, name string, dflt eval.Value, options map[string]eval
 	return Lookup2(ic, []string{name}, types.DefaultAnyType(), dflt, eval.EMPTY_MAP, eval.EMPTY_MAP, options, nil)
 }
 
+// Find is like Lookup but it returns the value together with a boolean to indicate if the value was
+// found, instead of returning a default or panicking when it wasn't. This makes it possible to tell a key
+// that is found with an undef value from a key that isn't found at all.
+var Find func(ic Invocation, name string, options map[string]eval.Value) (eval.Value, bool)
+
+// A KeyError is the error that a batch lookup got for one of its keys
+type KeyError struct {
+	Key   string
+	Error error
+}
+
+// A BatchResult is the outcome of a batch lookup. Values contains the keys that were found, in the order
+// they were given, together with their values. Errors contains the keys for which the lookup failed.
+// NotFound contains the keys that were neither found nor failed.
+type BatchResult struct {
+	Values   eval.OrderedMap
+	Errors   []KeyError
+	NotFound []string
+}
+
+// LookupBatch looks up each one of the given names as if by Find. Unlike Find, a lookup that fails doesn't
+// abort the batch. Its error is instead collected in the returned result and the lookup continues with the
+// next name.
+var LookupBatch func(ic Invocation, names []string, options map[string]eval.Value) *BatchResult
+
 var Lookup2 func(
 		ic Invocation,
 		names []string,
@@ -0,0 +1,60 @@
+package lookup
+
+import (
+	"fmt"
+
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+// LookupString performs a lookup of the given name and returns its value as a string. The given default
+// is returned when the name isn't found. A value that isn't a String is a type mismatch error.
+func LookupString(ic Invocation, name string, dflt string) string {
+	if v, ok := findTyped(ic, name, types.DefaultStringType()); ok {
+		return v.String()
+	}
+	return dflt
+}
+
+// LookupInt performs a lookup of the given name and returns its value as an int64. The given default is
+// returned when the name isn't found. A value that isn't an Integer is a type mismatch error.
+func LookupInt(ic Invocation, name string, dflt int64) int64 {
+	if v, ok := findTyped(ic, name, types.DefaultIntegerType()); ok {
+		return v.(*types.IntegerValue).Int()
+	}
+	return dflt
+}
+
+// LookupBool performs a lookup of the given name and returns its value as a bool. The given default is
+// returned when the name isn't found. A value that isn't a Boolean is a type mismatch error.
+func LookupBool(ic Invocation, name string, dflt bool) bool {
+	if v, ok := findTyped(ic, name, types.DefaultBooleanType()); ok {
+		return v.(*types.BooleanValue).Bool()
+	}
+	return dflt
+}
+
+// LookupStringSlice performs a lookup of the given name and returns its value as a string slice. The given
+// default is returned when the name isn't found. A value that isn't an Array[String] is a type mismatch
+// error.
+func LookupStringSlice(ic Invocation, name string, dflt []string) []string {
+	if v, ok := findTyped(ic, name, types.NewArrayType(types.DefaultStringType(), nil)); ok {
+		a := v.(*types.ArrayValue)
+		ss := make([]string, a.Len())
+		for i := range ss {
+			ss[i] = a.At(i).String()
+		}
+		return ss
+	}
+	return dflt
+}
+
+// findTyped finds the value of the given name and asserts that it is an instance of the given type. A
+// name that is found with an undef value is considered not found.
+func findTyped(ic Invocation, name string, t eval.Type) (eval.Value, bool) {
+	v, ok := Find(ic, name, nil)
+	if !ok || v == eval.UNDEF {
+		return nil, false
+	}
+	return eval.AssertInstance(func() string { return fmt.Sprintf(`value for key '%s'`, name) }, t, v), true
+}
@@ -0,0 +1,28 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+// DecodeValue decodes a value that was read by a provider using the "format" option. The format can be
+// "string" (the default), "yaml", or "json".
+func DecodeValue(c lookup.ProviderContext, options map[string]eval.Value, value []byte) (eval.Value, error) {
+	format := `string`
+	if fv, ok := options[`format`]; ok {
+		format = fv.String()
+	}
+	switch format {
+	case `string`:
+		return types.WrapString(string(value)), nil
+	case `yaml`, `json`:
+		// JSON is a subset of YAML so the YAML parser handles both
+		return impl.UnmarshalYaml(c.Invocation(), value), nil
+	default:
+		return nil, fmt.Errorf(`unknown format '%s'`, format)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/lyraproj/puppet-evaluator/types"
 	"github.com/lyraproj/hiera/lookup"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -31,3 +32,36 @@ func Environment(c lookup.ProviderContext, key string, _ map[string]eval.Value)
 	}
 	return nil, false
 }
+
+// EnvData performs a lookup of the key in the environment variables that start with the value of the
+// "prefix" option. The key is mapped to the name of a variable by upper casing it and replacing its dots
+// with underscores, so with the prefix "MYAPP_", the key "db" maps to the variable "MYAPP_DB".
+//
+// Lookups of dotted keys are made using the root of the key. When no variable with the mapped name
+// exists, the variables that extend the name with an underscore are returned as a hash, keyed by the
+// lower cased rest of their names. This allows "db.host" to find "MYAPP_DB_HOST". A key that matches no
+// variable is not found.
+func EnvData(c lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+	prefix := ``
+	if pv, ok := options[`prefix`]; ok {
+		prefix = pv.String()
+	}
+	name := prefix + strings.ToUpper(strings.Replace(key, `.`, `_`, -1))
+	if v, ok := os.LookupEnv(name); ok {
+		return types.WrapString(v), true
+	}
+
+	name += `_`
+	var em []*types.HashEntry
+	env := os.Environ()
+	sort.Strings(env)
+	for _, ev := range env {
+		if ei := strings.IndexRune(ev, '='); ei > len(name) && strings.HasPrefix(ev, name) {
+			em = append(em, types.WrapHashEntry2(strings.ToLower(ev[len(name):ei]), types.WrapString(ev[ei+1:])))
+		}
+	}
+	if len(em) == 0 {
+		return nil, false
+	}
+	return types.WrapHash(em), true
+}
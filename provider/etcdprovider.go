@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+const etcdClientKey = `etcd::client`
+
+// The dial timeout used when the "dial_timeout" option is not given
+const etcdDefaultDialTimeout = 5 * time.Second
+
+type etcdKeyValue struct {
+	Value string `json:"value"`
+}
+
+type etcdRangeResponse struct {
+	Kvs []etcdKeyValue `json:"kvs"`
+}
+
+// EtcdData performs a Get of the key, prefixed with the value of the optional "prefix" option, using the
+// JSON gateway of the etcd v3 API found at the required "endpoint" option. The optional "format" option
+// determines how the stored value is decoded. It can be "string" (the default), "yaml", or "json". The
+// optional "dial_timeout" option is the number of seconds to wait for a connection. The request is bound
+// to the invocation so it is cancelled along with it. A key that doesn't exist is reported as not found.
+func EtcdData(c lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+	ev, ok := options[`endpoint`]
+	if !ok {
+		panic(eval.Error(impl.HIERA_MISSING_REQUIRED_OPTION, issue.H{`option`: `endpoint`}))
+	}
+	name := key
+	if pv, ok := options[`prefix`]; ok {
+		name = pv.String() + key
+	}
+
+	body, _ := json.Marshal(map[string]string{`key`: base64.StdEncoding.EncodeToString([]byte(name))})
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(ev.String(), `/`)+`/v3/kv/range`, bytes.NewReader(body))
+	if err != nil {
+		panic(etcdError(name, err))
+	}
+	resp, err := etcdClient(c, options).Do(req.WithContext(c.Invocation()))
+	if err != nil {
+		panic(etcdError(name, err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		panic(etcdError(name, fmt.Errorf(`%s responded with %s`, ev, resp.Status)))
+	}
+
+	var rr etcdRangeResponse
+	if err = json.NewDecoder(resp.Body).Decode(&rr); err != nil {
+		panic(etcdError(name, err))
+	}
+	if len(rr.Kvs) == 0 {
+		return nil, false
+	}
+	value, err := base64.StdEncoding.DecodeString(rr.Kvs[0].Value)
+	if err != nil {
+		panic(etcdError(name, err))
+	}
+
+	dv, err := DecodeValue(c, options, value)
+	if err != nil {
+		panic(etcdError(name, err))
+	}
+	return dv, true
+}
+
+// etcdClient returns an HTTP client that uses the configured dial timeout. The client is created on first
+// use and then cached in the provider context.
+func etcdClient(c lookup.ProviderContext, options map[string]eval.Value) *http.Client {
+	timeout := etcdDefaultDialTimeout
+	cacheKey := etcdClientKey
+	if tv, ok := options[`dial_timeout`]; ok {
+		if ti, ok := tv.(eval.NumericValue); ok {
+			timeout = time.Duration(ti.Float() * float64(time.Second))
+		}
+		cacheKey += `::` + tv.String()
+	}
+	if cv, ok := c.CachedValue(cacheKey); ok {
+		return cv.(*types.RuntimeValue).Interface().(*http.Client)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{Timeout: timeout}).DialContext}}
+	c.Cache(cacheKey, types.WrapRuntime(client))
+	return client
+}
+
+func etcdError(key string, err error) issue.Reported {
+	return eval.Error(impl.HIERA_PROVIDER_ERROR, issue.H{`provider`: `etcd_data`, `key`: key, `detail`: err.Error()})
+}
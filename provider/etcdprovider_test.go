@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+
+	// Ensure initialization
+	_ "github.com/lyraproj/hiera/functions"
+	_ "github.com/lyraproj/puppet-evaluator/pcore"
+)
+
+// etcdServer returns a server that answers range requests of the etcd v3 JSON gateway using the given
+// key/value pairs
+func etcdServer(kvs map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != `/v3/kv/range` {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Key string `json:"key"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key, err := base64.StdEncoding.DecodeString(req.Key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var rr etcdRangeResponse
+		if v, ok := kvs[string(key)]; ok {
+			rr.Kvs = []etcdKeyValue{{Value: base64.StdEncoding.EncodeToString([]byte(v))}}
+		}
+		json.NewEncoder(w).Encode(&rr)
+	}))
+}
+
+func etcdLookup(endpoint string, options map[string]eval.Value, actor func(ic lookup.Invocation)) error {
+	no := map[string]eval.Value{`endpoint`: types.WrapString(endpoint)}
+	for k, v := range options {
+		no[k] = v
+	}
+	return lookup.TryWithParent(context.Background(), EtcdData, no, func(c eval.Context) error {
+		actor(impl.NewInvocation(c))
+		return nil
+	})
+}
+
+func TestEtcdData_found(t *testing.T) {
+	server := etcdServer(map[string]string{`/app/greeting`: `hello`})
+	defer server.Close()
+
+	err := etcdLookup(server.URL, map[string]eval.Value{`prefix`: types.WrapString(`/app/`)}, func(ic lookup.Invocation) {
+		if v, ok := lookup.Find(ic, `greeting`, nil); !ok || v.String() != `hello` {
+			t.Errorf(`unexpected value %v`, v)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEtcdData_notFound(t *testing.T) {
+	server := etcdServer(map[string]string{})
+	defer server.Close()
+
+	err := etcdLookup(server.URL, nil, func(ic lookup.Invocation) {
+		if v, ok := lookup.Find(ic, `greeting`, nil); ok || v != nil {
+			t.Errorf(`expected no value, got %v`, v)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEtcdData_formats(t *testing.T) {
+	tests := []struct {
+		format   string
+		value    string
+		expected string
+	}{
+		{`string`, `{"a": 1}`, `{"a": 1}`},
+		{`json`, `{"a": 1}`, `{'a' => 1}`},
+		{`yaml`, "a: 1\nb: [x, z]\n", `{'a' => 1, 'b' => ['x', 'z']}`},
+	}
+	kvs := make(map[string]string, len(tests))
+	for _, tt := range tests {
+		kvs[tt.format] = tt.value
+	}
+	server := etcdServer(kvs)
+	defer server.Close()
+
+	for _, tt := range tests {
+		err := etcdLookup(server.URL, map[string]eval.Value{`format`: types.WrapString(tt.format)}, func(ic lookup.Invocation) {
+			if v, ok := lookup.Find(ic, tt.format, nil); !ok || v.String() != tt.expected {
+				t.Errorf(`format %s: expected %s, got %v`, tt.format, tt.expected, v)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEtcdData_connectionError(t *testing.T) {
+	// Obtain an address that nothing listens on
+	l, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := fmt.Sprintf(`http://%s`, l.Addr())
+	l.Close()
+
+	err = etcdLookup(endpoint, nil, func(ic lookup.Invocation) {
+		lookup.Find(ic, `greeting`, nil)
+	})
+	if ri, ok := err.(issue.Reported); !ok || ri.Code() != impl.HIERA_PROVIDER_ERROR {
+		t.Errorf(`expected a %s error, got %v`, impl.HIERA_PROVIDER_ERROR, err)
+	}
+}
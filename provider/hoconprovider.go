@@ -0,0 +1,25 @@
+package provider
+
+import (
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+)
+
+// HoconData is a data_hash function that returns the hash that is read from the HOCON file appointed by
+// the required "path" option. Includes are resolved relative to the directory of that file and
+// substitutions are resolved within the file. A file that doesn't exist yields an empty hash.
+func HoconData(c lookup.ProviderContext, options map[string]eval.Value) eval.OrderedMap {
+	pv, ok := options[`path`]
+	if !ok {
+		panic(eval.Error(impl.HIERA_MISSING_REQUIRED_OPTION, issue.H{`option`: `path`}))
+	}
+	path := pv.String()
+	if data, ok := lookup.CachedFileData(c, path, func(content []byte) eval.Value {
+		return impl.UnmarshalHoconFile(c.Invocation(), `hocon_data`, path, content)
+	}); ok {
+		return data.(eval.OrderedMap)
+	}
+	return eval.EMPTY_MAP
+}
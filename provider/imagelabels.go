@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+// The Docker daemon address used when neither the "host" option nor the DOCKER_HOST environment
+// variable is set
+const defaultDockerHost = `unix:///var/run/docker.sock`
+
+// The time to wait for a connection to the Docker daemon, and then for the headers of its response
+const dockerDialTimeout = 5 * time.Second
+const dockerResponseTimeout = 30 * time.Second
+
+type imageInspect struct {
+	Config struct {
+		Labels map[string]string
+	}
+}
+
+// ImageLabels is a data_hash function that returns the labels of the Docker/OCI image given by the required
+// "image" option, e.g. "myorg/app:1.2". The image must be known to the Docker daemon at the optional "host"
+// option, which defaults to the value of the DOCKER_HOST environment variable or the local daemon socket.
+// Both unix:// and tcp:// addresses are supported. A missing image is an error and an image without labels
+// yields an empty hash. The request is bound to the invocation so it is cancelled along with it.
+func ImageLabels(c lookup.ProviderContext, options map[string]eval.Value) eval.OrderedMap {
+	iv, ok := options[`image`]
+	if !ok {
+		panic(eval.Error(impl.HIERA_MISSING_REQUIRED_OPTION, issue.H{`option`: `image`}))
+	}
+	image := iv.String()
+
+	host := os.Getenv(`DOCKER_HOST`)
+	if hv, ok := options[`host`]; ok {
+		host = hv.String()
+	}
+	if host == `` {
+		host = defaultDockerHost
+	}
+	client, base, err := dockerClient(host)
+	if err != nil {
+		panic(imageLabelsError(image, err))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, base+`/images/`+escapeSegments(image)+`/json`, nil)
+	if err != nil {
+		panic(imageLabelsError(image, err))
+	}
+	resp, err := client.Do(req.WithContext(c.Invocation()))
+	if err != nil {
+		panic(imageLabelsError(image, err))
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		panic(imageLabelsError(image, fmt.Errorf(`no such image`)))
+	default:
+		panic(imageLabelsError(image, fmt.Errorf(`%s responded with %s`, host, resp.Status)))
+	}
+
+	var ii imageInspect
+	if err = json.NewDecoder(resp.Body).Decode(&ii); err != nil {
+		panic(imageLabelsError(image, err))
+	}
+	labels := ii.Config.Labels
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	es := make([]*types.HashEntry, len(keys))
+	for i, k := range keys {
+		es[i] = types.WrapHashEntry2(k, types.WrapString(labels[k]))
+	}
+	return types.WrapHash(es)
+}
+
+// dockerClient returns an HTTP client for the given Docker daemon address together with the base URL
+// to use for requests
+func dockerClient(host string) (*http.Client, string, error) {
+	hu, err := url.Parse(host)
+	if err != nil {
+		return nil, ``, err
+	}
+	dialer := &net.Dialer{Timeout: dockerDialTimeout}
+	switch hu.Scheme {
+	case `unix`:
+		socket := hu.Path
+		return &http.Client{Transport: &http.Transport{
+			ResponseHeaderTimeout: dockerResponseTimeout,
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, `unix`, socket)
+			}}}, `http://docker`, nil
+	case `tcp`, `http`:
+		return &http.Client{Transport: &http.Transport{
+			ResponseHeaderTimeout: dockerResponseTimeout,
+			DialContext:           dialer.DialContext}}, `http://` + strings.TrimSuffix(hu.Host, `/`), nil
+	default:
+		return nil, ``, fmt.Errorf(`unsupported Docker host '%s'`, host)
+	}
+}
+
+// escapeSegments escapes each slash separated segment of the given image reference so that characters
+// like '?', '#', and '%' are sent as part of the reference
+func escapeSegments(ref string) string {
+	segments := strings.Split(ref, `/`)
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, `/`)
+}
+
+func imageLabelsError(image string, err error) issue.Reported {
+	return eval.Error(impl.HIERA_PROVIDER_ERROR, issue.H{`provider`: `image_labels`, `key`: image, `detail`: err.Error()})
+}
@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+// dockerServer returns a server that answers image inspect requests of the Docker API. The given map
+// contains the labels of each known image. A nil map of labels yields an image without labels.
+func dockerServer(images map[string]map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.EscapedPath()
+		if r.Method != http.MethodGet || !strings.HasPrefix(path, `/images/`) || !strings.HasSuffix(path, `/json`) {
+			http.NotFound(w, r)
+			return
+		}
+		labels, ok := images[strings.TrimSuffix(strings.TrimPrefix(path, `/images/`), `/json`)]
+		if !ok {
+			http.Error(w, `{"message":"no such image"}`, http.StatusNotFound)
+			return
+		}
+		var ii imageInspect
+		ii.Config.Labels = labels
+		json.NewEncoder(w).Encode(&ii)
+	}))
+}
+
+// imageLabels returns the labels of the given image, as reported by the daemon at the given host
+func imageLabels(host, image string) (labels eval.Value, err error) {
+	tp := func(c lookup.ProviderContext, _ string, options map[string]eval.Value) (eval.Value, bool) {
+		return ImageLabels(c, options), true
+	}
+	options := map[string]eval.Value{`host`: types.WrapString(host), `image`: types.WrapString(image)}
+	err = lookup.TryWithParent(context.Background(), tp, options, func(c eval.Context) error {
+		labels, _ = lookup.Find(impl.NewInvocation(c), `labels`, nil)
+		return nil
+	})
+	return
+}
+
+func TestImageLabels_labels(t *testing.T) {
+	server := dockerServer(map[string]map[string]string{
+		`myorg/app:1.2`: {`version`: `1.2`, `maintainer`: `ops`},
+		`odd%3Fref`:     {`odd`: `yes`}})
+	defer server.Close()
+	host := strings.Replace(server.URL, `http://`, `tcp://`, 1)
+
+	labels, err := imageLabels(host, `myorg/app:1.2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels.String() != `{'maintainer' => 'ops', 'version' => '1.2'}` {
+		t.Errorf(`unexpected labels %s`, labels)
+	}
+
+	if labels, err = imageLabels(host, `odd?ref`); err != nil {
+		t.Fatal(err)
+	}
+	if labels.String() != `{'odd' => 'yes'}` {
+		t.Errorf(`expected the reference to be escaped, got labels %s`, labels)
+	}
+}
+
+func TestImageLabels_noLabels(t *testing.T) {
+	server := dockerServer(map[string]map[string]string{`myorg/app:1.2`: nil})
+	defer server.Close()
+
+	labels, err := imageLabels(server.URL, `myorg/app:1.2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, ok := labels.(eval.OrderedMap); !ok || hash.Len() != 0 {
+		t.Errorf(`expected an empty hash, got %v`, labels)
+	}
+}
+
+func TestImageLabels_missingImage(t *testing.T) {
+	server := dockerServer(map[string]map[string]string{})
+	defer server.Close()
+
+	_, err := imageLabels(server.URL, `myorg/app:1.2`)
+	if ri, ok := err.(issue.Reported); !ok || ri.Code() != impl.HIERA_PROVIDER_ERROR || !strings.Contains(err.Error(), `no such image`) {
+		t.Errorf(`expected a %s error for a missing image, got %v`, impl.HIERA_PROVIDER_ERROR, err)
+	}
+}
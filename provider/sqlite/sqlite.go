@@ -0,0 +1,72 @@
+// Package sqlite provides a lookup_key function that reads values from a SQLite database. It is kept apart
+// from the other providers since the SQLite driver requires cgo.
+package sqlite
+
+import (
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/hiera/provider"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+
+	// Register the sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Data performs a lookup of the key in the SQLite database file given by the required "path" option.
+// The required "query" option is the SQL query to run, with a single placeholder for the key, e.g.
+// "SELECT value FROM config WHERE key = ?". The first column of the first row is the value. The optional
+// "format" option determines how that value is decoded. It can be "string" (the default), "yaml", or
+// "json". A query that returns no rows means that the key is not found.
+//
+// The database is opened in read-only mode for the duration of the query.
+func Data(c lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+	pv, ok := options[`path`]
+	if !ok {
+		panic(eval.Error(impl.HIERA_MISSING_REQUIRED_OPTION, issue.H{`option`: `path`}))
+	}
+	qv, ok := options[`query`]
+	if !ok {
+		panic(eval.Error(impl.HIERA_MISSING_REQUIRED_OPTION, issue.H{`option`: `query`}))
+	}
+
+	db, err := sql.Open(`sqlite3`, dsn(pv.String()))
+	if err != nil {
+		panic(dataError(key, err))
+	}
+	defer db.Close()
+
+	var value []byte
+	err = db.QueryRowContext(c.Invocation(), qv.String(), key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, false
+	}
+	if err != nil {
+		panic(dataError(key, err))
+	}
+
+	dv, err := provider.DecodeValue(c, options, value)
+	if err != nil {
+		panic(dataError(key, err))
+	}
+	return dv, true
+}
+
+// dsn returns the URI that opens the database file at the given path in read-only mode. Each segment of
+// the path is escaped so that characters like '?', '#', and '%' are not taken as URI delimiters.
+func dsn(path string) string {
+	segments := strings.Split(filepath.ToSlash(path), `/`)
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return `file:` + strings.Join(segments, `/`) + `?mode=ro`
+}
+
+func dataError(key string, err error) issue.Reported {
+	return eval.Error(impl.HIERA_PROVIDER_ERROR, issue.H{`provider`: `sqlite_data`, `key`: key, `detail`: err.Error()})
+}
@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+
+	// Ensure initialization
+	_ "github.com/lyraproj/hiera/functions"
+	_ "github.com/lyraproj/puppet-evaluator/pcore"
+)
+
+// createDatabase creates a database with a config table that contains the given rows. The directory of
+// the database contains characters that must be escaped in a DSN.
+func createDatabase(t *testing.T, rows map[string]string) string {
+	t.Helper()
+	tmp, err := ioutil.TempDir(``, `hiera`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmp, `what?#100%`)
+	if err = os.Mkdir(dir, 0755); err != nil {
+		os.RemoveAll(tmp)
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, `config.db`)
+	db, err := sql.Open(`sqlite3`, strings.Replace(dsn(path), `mode=ro`, `mode=rwc`, 1))
+	if err == nil {
+		defer db.Close()
+		_, err = db.Exec(`CREATE TABLE config (key TEXT PRIMARY KEY, value TEXT)`)
+	}
+	for k, v := range rows {
+		if err == nil {
+			_, err = db.Exec(`INSERT INTO config VALUES (?, ?)`, k, v)
+		}
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		t.Fatal(err)
+	}
+	return path
+}
+
+func withDatabase(t *testing.T, rows map[string]string, format string, actor func(ic lookup.Invocation)) {
+	t.Helper()
+	path := createDatabase(t, rows)
+	defer os.RemoveAll(filepath.Dir(filepath.Dir(path)))
+
+	options := map[string]eval.Value{
+		`path`:  types.WrapString(path),
+		`query`: types.WrapString(`SELECT value FROM config WHERE key = ?`)}
+	if format != `` {
+		options[`format`] = types.WrapString(format)
+	}
+	err := lookup.TryWithParent(context.Background(), Data, options, func(c eval.Context) error {
+		actor(impl.NewInvocation(c))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestData_found(t *testing.T) {
+	withDatabase(t, map[string]string{`greeting`: `hello`}, ``, func(ic lookup.Invocation) {
+		if v, ok := lookup.Find(ic, `greeting`, nil); !ok || v.String() != `hello` {
+			t.Errorf(`unexpected value %v`, v)
+		}
+	})
+}
+
+func TestData_noRows(t *testing.T) {
+	withDatabase(t, map[string]string{`greeting`: `hello`}, ``, func(ic lookup.Invocation) {
+		if v, ok := lookup.Find(ic, `farewell`, nil); ok || v != nil {
+			t.Errorf(`expected no value, got %v`, v)
+		}
+	})
+}
+
+func TestData_formats(t *testing.T) {
+	tests := []struct {
+		format   string
+		value    string
+		expected string
+	}{
+		{`string`, `{"a": 1}`, `{"a": 1}`},
+		{`json`, `{"a": 1}`, `{'a' => 1}`},
+		{`yaml`, "a: 1\nb: [x, z]\n", `{'a' => 1, 'b' => ['x', 'z']}`},
+	}
+	for _, tt := range tests {
+		withDatabase(t, map[string]string{`data`: tt.value}, tt.format, func(ic lookup.Invocation) {
+			v, ok := lookup.Find(ic, `data`, nil)
+			if !ok || v.String() != tt.expected {
+				t.Errorf(`format %s: expected %s, got %v`, tt.format, tt.expected, v)
+			}
+		})
+	}
+}
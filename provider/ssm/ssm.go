@@ -0,0 +1,68 @@
+// Package ssm provides a lookup_key function that reads parameters from the AWS Systems Manager
+// Parameter Store. It is kept apart from the other providers so that only those who use it link the AWS SDK.
+package ssm
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/issue/issue"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+)
+
+const clientKey = `ssm::client`
+
+// newClient creates an SSM client that uses the given configuration
+var newClient = func(cfg *aws.Config) (ssmiface.SSMAPI, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{Config: *cfg, SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, err
+	}
+	return ssm.New(sess), nil
+}
+
+// Data performs a lookup of a parameter in the AWS Systems Manager Parameter Store. The name of the
+// parameter is the key, prefixed with the value of the optional "prefix" option. The optional "region"
+// option selects the AWS region. Credentials are obtained using the default credential chain of the AWS SDK
+// and SecureString parameters are decrypted. A parameter that doesn't exist is reported as not found.
+func Data(c lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
+	name := key
+	if pv, ok := options[`prefix`]; ok {
+		name = pv.String() + key
+	}
+	out, err := client(c, options).GetParameterWithContext(c.Invocation(), &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true)})
+	if err != nil {
+		if ae, ok := err.(awserr.Error); ok && ae.Code() == ssm.ErrCodeParameterNotFound {
+			return nil, false
+		}
+		panic(eval.Error(impl.HIERA_PROVIDER_ERROR, issue.H{`provider`: `ssm_data`, `key`: name, `detail`: err.Error()}))
+	}
+	return types.WrapString(aws.StringValue(out.Parameter.Value)), true
+}
+
+// client returns the client for the configured region. The client is created on first use and then
+// cached in the provider context.
+func client(c lookup.ProviderContext, options map[string]eval.Value) ssmiface.SSMAPI {
+	cfg := aws.NewConfig()
+	cacheKey := clientKey
+	if rv, ok := options[`region`]; ok {
+		cfg = cfg.WithRegion(rv.String())
+		cacheKey += `::` + rv.String()
+	}
+	if cv, ok := c.CachedValue(cacheKey); ok {
+		return cv.(*types.RuntimeValue).Interface().(ssmiface.SSMAPI)
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		panic(eval.Error(impl.HIERA_PROVIDER_ERROR, issue.H{`provider`: `ssm_data`, `key`: clientKey, `detail`: err.Error()}))
+	}
+	c.Cache(cacheKey, types.WrapRuntime(client))
+	return client
+}
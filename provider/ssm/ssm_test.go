@@ -0,0 +1,105 @@
+package ssm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/lyraproj/hiera/impl"
+	"github.com/lyraproj/hiera/lookup"
+	"github.com/lyraproj/puppet-evaluator/eval"
+	"github.com/lyraproj/puppet-evaluator/types"
+
+	// Ensure initialization
+	_ "github.com/lyraproj/hiera/functions"
+	_ "github.com/lyraproj/puppet-evaluator/pcore"
+)
+
+type parameter struct {
+	value  string
+	secure bool
+}
+
+// stubClient is an SSM client that serves parameters from a map. SecureString parameters are stored
+// encrypted, i.e. reversed, and decrypted when a request asks for it.
+type stubClient struct {
+	ssmiface.SSMAPI
+	parameters map[string]parameter
+	region     string
+}
+
+func (s *stubClient) GetParameterWithContext(_ aws.Context, in *ssm.GetParameterInput, _ ...request.Option) (*ssm.GetParameterOutput, error) {
+	p, ok := s.parameters[aws.StringValue(in.Name)]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, `parameter not found`, nil)
+	}
+	value, typ := p.value, ssm.ParameterTypeString
+	if p.secure {
+		typ = ssm.ParameterTypeSecureString
+		if aws.BoolValue(in.WithDecryption) {
+			value = reverse(value)
+		}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Name: in.Name, Type: aws.String(typ), Value: aws.String(value)}}, nil
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func withStub(t *testing.T, parameters map[string]parameter, options map[string]eval.Value, actor func(ic lookup.Invocation, stub *stubClient)) {
+	t.Helper()
+	stub := &stubClient{parameters: parameters}
+	saved := newClient
+	newClient = func(cfg *aws.Config) (ssmiface.SSMAPI, error) {
+		stub.region = aws.StringValue(cfg.Region)
+		return stub, nil
+	}
+	defer func() { newClient = saved }()
+
+	err := lookup.TryWithParent(context.Background(), Data, options, func(c eval.Context) error {
+		actor(impl.NewInvocation(c), stub)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestData_found(t *testing.T) {
+	options := map[string]eval.Value{`prefix`: types.WrapString(`/app/`), `region`: types.WrapString(`eu-north-1`)}
+	withStub(t, map[string]parameter{`/app/db_host`: {value: `db.example.com`}}, options, func(ic lookup.Invocation, stub *stubClient) {
+		v, ok := lookup.Find(ic, `db_host`, nil)
+		if !ok || v.String() != `db.example.com` {
+			t.Errorf(`unexpected value %v`, v)
+		}
+		if stub.region != `eu-north-1` {
+			t.Errorf(`expected the client to use region eu-north-1, got '%s'`, stub.region)
+		}
+	})
+}
+
+func TestData_notFound(t *testing.T) {
+	withStub(t, map[string]parameter{}, nil, func(ic lookup.Invocation, _ *stubClient) {
+		if v, ok := lookup.Find(ic, `db_host`, nil); ok || v != nil {
+			t.Errorf(`expected no value, got %v`, v)
+		}
+	})
+}
+
+func TestData_secureString(t *testing.T) {
+	withStub(t, map[string]parameter{`db_password`: {value: reverse(`s3cr3t`), secure: true}}, nil, func(ic lookup.Invocation, _ *stubClient) {
+		v, ok := lookup.Find(ic, `db_password`, nil)
+		if !ok || v.String() != `s3cr3t` {
+			t.Errorf(`expected the decrypted value, got %v`, v)
+		}
+	})
+}
@@ -10,25 +10,40 @@ import (
 
 var YamlDataKey = `yaml::data`
 
-// Environment provider performs a lookup in the current environment. The key can either be just
-// "env" in which case all current environment variables will be returned as an OrderedMap, or
-// prefixed with "env::" in which case the rest of the key is interpreted as the environment variable
-// to look for.
+// Yaml performs a lookup in the hash that is read from the YAML file appointed by the required "path"
+// option. A file that doesn't exist yields an empty hash.
+//
+// The optional "allow_duplicate_keys" option can be set to false to make a file that contains the same
+// key more than once in a mapping an error. By default, the last occurrence of the key wins.
 func Yaml(c lookup.ProviderContext, key string, options map[string]eval.Value) (eval.Value, bool) {
-	data, ok := c.CachedValue(YamlDataKey)
+	strict := false
+	if dv, ok := options[`allow_duplicate_keys`].(*types.BooleanValue); ok {
+		strict = !dv.Bool()
+	}
+
+	// Data that was parsed leniently must not be used when strict parsing is requested and vice versa
+	dataKey := YamlDataKey
+	variant := ``
+	if strict {
+		variant = `strict`
+		dataKey += `::` + variant
+	}
+
+	data, ok := c.CachedValue(dataKey)
 	if !ok {
 		if v, ok := options[`path`]; ok {
 			path := v.String()
-			if bin, ok := types.BinaryFromFile2(c.Invocation(), path); ok {
-				data = impl.UnmarshalYaml(c.Invocation(), bin.Bytes())
-				if _, ok := data.(eval.OrderedMap); !ok {
+			if data, ok = impl.CachedFileVariant(c, path, variant, func(content []byte) eval.Value {
+				fd := impl.UnmarshalYamlFile(c.Invocation(), `yaml_data`, path, content, strict)
+				if _, ok := fd.(eval.OrderedMap); !ok {
 					panic(eval.Error(impl.HIERA_YAML_NOT_HASH, issue.H{`path`: path}))
 				}
-			} else {
+				return fd
+			}); !ok {
 				// File not found. This is OK but yields an empty map
 				data = eval.EMPTY_MAP
 			}
-			c.Cache(YamlDataKey, data)
+			c.Cache(dataKey, data)
 		} else {
 			panic(eval.Error(impl.HIERA_MISSING_REQUIRED_OPTION, issue.H{`option`: `path`}))
 		}
@@ -36,4 +51,3 @@ func Yaml(c lookup.ProviderContext, key string, options map[string]eval.Value) (
 	hash, _ := data.(eval.OrderedMap)
 	return hash.Get4(key)
 }
-